@@ -0,0 +1,61 @@
+// fake-scalarm serves experiments from a local definition file as Scalarm services,
+// so Scalarm Simulation Manager can be run against it with 'information_service_url' set to '<listen>/information'
+// and 'development' set to true.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/scalarm/scalarm_simulation_manager_go/fakeScalarm"
+)
+
+// Fatal utility function to log a fatal error
+func Fatal(err error) {
+	fmt.Printf("[Fatal error] %v\n", err)
+	os.Exit(1)
+}
+
+func main() {
+	listen := flag.String("listen", "localhost:11300", "address to listen on")
+	definitionPath := flag.String("experiments", "experiments.json", "JSON file with definitions of experiments")
+	user := flag.String("user", "", "user name required from workers, not checked if empty")
+	password := flag.String("pass", "", "password required from workers")
+	flag.Parse()
+
+	experiments, err := fakeScalarm.LoadExperiments(*definitionPath)
+	if err != nil {
+		Fatal(err)
+	}
+
+	server := fakeScalarm.NewServer(experiments...)
+	server.User = *user
+	server.Password = *password
+
+	// results received so far are printed on exit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(server.Completions())
+		os.Exit(0)
+	}()
+
+	fmt.Printf("[fake-scalarm] Serving %v experiment(s) at http://%s/information\n", len(experiments), *listen)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("[fake-scalarm] %s %s\n", r.Method, r.URL.Path)
+		server.ServeHTTP(w, r)
+	})
+
+	if err := http.ListenAndServe(*listen, handler); err != nil {
+		Fatal(err)
+	}
+}
@@ -0,0 +1,368 @@
+// Package fakeScalarm provides an in-process fake of Scalarm services - Information Service, Experiment Manager
+// and Storage Manager - for integration tests and demos of Scalarm Simulation Manager.
+package fakeScalarm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Experiment is a definition of an experiment served by the fake
+type Experiment struct {
+	ID string `json:"id"`
+	// path to the code base zip archive, relative to the definition file
+	CodeBasePath string `json:"code_base"`
+	CodeBase     []byte `json:"-"`
+	// input parameters of consecutive simulation runs
+	InputParameters      []map[string]interface{} `json:"input_parameters"`
+	ExecutionConstraints map[string]interface{}   `json:"execution_constraints,omitempty"`
+	// time to wait sent to workers when all simulation runs are sent but some of them are not completed yet
+	WaitInSeconds int `json:"wait_in_seconds"`
+}
+
+// Report is a form sent by a worker about a simulation run, e.g. its results or progress info
+type Report struct {
+	ExperimentID string
+	SimulationID int
+	Values       url.Values
+}
+
+// Upload is a file uploaded by a worker to Storage Manager
+type Upload struct {
+	ExperimentID string
+	SimulationID int
+	// 'output' for binary results and 'stdout' for the standard output of a simulation run
+	Kind     string
+	FileName string
+	Content  []byte
+}
+
+type experimentState struct {
+	Experiment
+	sent      int
+	completed map[int]bool
+	// simulation runs given back by workers, they are sent again before the remaining ones
+	returned []int
+}
+
+// Server fakes Scalarm services, all of them are served from the same address
+// which is returned as the only Experiment and Storage Manager
+type Server struct {
+	// credentials required from workers, not checked when User is empty
+	User     string
+	Password string
+
+	mutex            sync.Mutex
+	experiments      []*experimentState
+	requests         []string
+	completions      []Report
+	progressInfos    []Report
+	hostInfos        []Report
+	performanceStats []Report
+	uploads          []Upload
+}
+
+// NewServer returns a fake serving the given experiments, it should be started e.g. with httptest.NewServer
+func NewServer(experiments ...Experiment) *Server {
+	server := &Server{}
+	for _, experiment := range experiments {
+		if experiment.WaitInSeconds <= 0 {
+			experiment.WaitInSeconds = 1
+		}
+		server.experiments = append(server.experiments, &experimentState{Experiment: experiment, completed: map[int]bool{}})
+	}
+
+	return server
+}
+
+// LoadExperiments reads definitions of experiments from a JSON file together with their code bases
+func LoadExperiments(definitionPath string) ([]Experiment, error) {
+	content, err := ioutil.ReadFile(definitionPath)
+	if err != nil {
+		return nil, errors.New("Could not open file " + definitionPath + ".")
+	}
+
+	experiments := []Experiment{}
+	if err := json.Unmarshal(content, &experiments); err != nil {
+		return nil, fmt.Errorf("Incorrect JSON in the file %s: %v", definitionPath, err)
+	}
+
+	for i := range experiments {
+		codeBasePath := experiments[i].CodeBasePath
+		if !filepath.IsAbs(codeBasePath) {
+			codeBasePath = filepath.Join(filepath.Dir(definitionPath), codeBasePath)
+		}
+
+		if experiments[i].CodeBase, err = ioutil.ReadFile(codeBasePath); err != nil {
+			return nil, errors.New("Could not open code base " + codeBasePath + ".")
+		}
+	}
+
+	return experiments, nil
+}
+
+// Requests returns all received requests as 'METHOD /path'
+func (s *Server) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string{}, s.requests...)
+}
+
+// Completions returns results of simulation runs sent to 'mark_as_complete'
+func (s *Server) Completions() []Report {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Report{}, s.completions...)
+}
+
+// Completion returns results of the given simulation run, ok is false when they were not sent
+func (s *Server) Completion(experimentID string, simulationID int) (report Report, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, completion := range s.completions {
+		if completion.ExperimentID == experimentID && completion.SimulationID == simulationID {
+			return completion, true
+		}
+	}
+
+	return Report{}, false
+}
+
+// ProgressInfos returns intermediate results sent by progress monitors
+func (s *Server) ProgressInfos() []Report {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Report{}, s.progressInfos...)
+}
+
+// HostInfos returns information about hosts executing simulation runs
+func (s *Server) HostInfos() []Report {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Report{}, s.hostInfos...)
+}
+
+// PerformanceStats returns performance statistics of simulation runs
+func (s *Server) PerformanceStats() []Report {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Report{}, s.performanceStats...)
+}
+
+// Uploads returns files uploaded to Storage Manager
+func (s *Server) Uploads() []Upload {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Upload{}, s.uploads...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	if s.User != "" {
+		if user, password, ok := r.BasicAuth(); !ok || user != s.User || password != s.Password {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// Information Service may be served with any path prefix, e.g. '/information/experiment_managers'
+	if strings.HasSuffix(r.URL.Path, "/experiment_managers") || strings.HasSuffix(r.URL.Path, "/storage_managers") {
+		writeJSON(w, []string{r.Host})
+		return
+	}
+
+	// experiments/random_experiment
+	// experiments/:id/(next_simulation|code_base)
+	// experiments/:id/simulations/:index[/(mark_as_complete|progress_info|host_info|performance_stats|stdout)]
+	// DELETE experiments/:id/simulations/:index gives a simulation run back
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "experiments" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "random_experiment" {
+		s.randomExperiment(w)
+		return
+	}
+
+	experiment := s.experiment(parts[1])
+	if experiment == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 3 && parts[2] == "next_simulation" {
+		s.nextSimulation(w, experiment)
+	} else if len(parts) == 3 && parts[2] == "code_base" {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(experiment.CodeBase)
+	} else if len(parts) >= 4 && parts[2] == "simulations" {
+		simulationID, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		action := ""
+		if len(parts) == 5 {
+			action = parts[4]
+		}
+
+		s.simulationRun(w, r, experiment, simulationID, action)
+	} else {
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) experiment(id string) *experimentState {
+	for _, experiment := range s.experiments {
+		if experiment.ID == id {
+			return experiment
+		}
+	}
+
+	return nil
+}
+
+// isReturned tells whether the simulation run is waiting to be sent again
+func (experiment *experimentState) isReturned(simulationID int) bool {
+	for _, returned := range experiment.returned {
+		if returned == simulationID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// randomExperiment returns id of an experiment with simulation runs left to send or an empty body
+func (s *Server) randomExperiment(w http.ResponseWriter) {
+	for _, experiment := range s.experiments {
+		if experiment.sent < len(experiment.InputParameters) || len(experiment.returned) > 0 {
+			fmt.Fprint(w, experiment.ID)
+			return
+		}
+	}
+}
+
+// nextSimulation hands out simulation runs one after another, starting with the given back ones, asks to wait while the sent ones are being computed
+// and answers 'all_sent' when all of them are completed
+func (s *Server) nextSimulation(w http.ResponseWriter, experiment *experimentState) {
+	if len(experiment.returned) > 0 || experiment.sent < len(experiment.InputParameters) {
+		var simulationID int
+		if len(experiment.returned) > 0 {
+			simulationID, experiment.returned = experiment.returned[0], experiment.returned[1:]
+		} else {
+			experiment.sent++
+			simulationID = experiment.sent
+		}
+
+		simulationRun := map[string]interface{}{
+			"status":           "ok",
+			"simulation_id":    simulationID,
+			"input_parameters": experiment.InputParameters[simulationID-1],
+		}
+		if experiment.ExecutionConstraints != nil {
+			simulationRun["execution_constraints"] = experiment.ExecutionConstraints
+		}
+
+		writeJSON(w, simulationRun)
+	} else if len(experiment.completed) < experiment.sent {
+		writeJSON(w, map[string]interface{}{"status": "wait", "duration_in_seconds": experiment.WaitInSeconds})
+	} else {
+		writeJSON(w, map[string]interface{}{"status": "all_sent", "reason": "There is no more simulations"})
+	}
+}
+
+func (s *Server) simulationRun(w http.ResponseWriter, r *http.Request, experiment *experimentState, simulationID int, action string) {
+	if simulationID < 1 || simulationID > experiment.sent {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == "PUT" && (action == "" || action == "stdout") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		content, err := ioutil.ReadAll(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		kind := "output"
+		if action == "stdout" {
+			kind = "stdout"
+		}
+
+		s.uploads = append(s.uploads, Upload{experiment.ID, simulationID, kind, header.Filename, content})
+		writeJSON(w, map[string]string{"status": "ok"})
+		return
+	}
+
+	// resetting a simulation run which is neither completed nor already given back
+	if r.Method == "DELETE" && action == "" {
+		if !experiment.completed[simulationID] && !experiment.isReturned(simulationID) {
+			experiment.returned = append(experiment.returned, simulationID)
+		}
+		writeJSON(w, map[string]string{"status": "ok"})
+		return
+	}
+
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report := Report{experiment.ID, simulationID, r.PostForm}
+
+	switch action {
+	case "mark_as_complete":
+		experiment.completed[simulationID] = true
+		s.completions = append(s.completions, report)
+	case "progress_info":
+		s.progressInfos = append(s.progressInfos, report)
+	case "host_info":
+		s.hostInfos = append(s.hostInfos, report)
+	case "performance_stats":
+		s.performanceStats = append(s.performanceStats, report)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
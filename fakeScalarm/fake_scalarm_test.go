@@ -0,0 +1,138 @@
+package fakeScalarm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func getNextSimulation(t *testing.T, serverURL string) map[string]interface{} {
+	resp, err := http.Get(serverURL + "/experiments/1/next_simulation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	simulationRun := map[string]interface{}{}
+	if err := json.NewDecoder(resp.Body).Decode(&simulationRun); err != nil {
+		t.Fatal(err)
+	}
+
+	return simulationRun
+}
+
+func TestNextSimulationShouldSendSimulationRunsThenWaitThenAllSent(t *testing.T) {
+	// === GIVEN ===
+	fake := NewServer(Experiment{
+		ID:              "1",
+		InputParameters: []map[string]interface{}{{"parameter1": 1}},
+		WaitInSeconds:   3,
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	// === WHEN ===
+	first := getNextSimulation(t, server.URL)
+	second := getNextSimulation(t, server.URL)
+	http.PostForm(server.URL+"/experiments/1/simulations/1/mark_as_complete", url.Values{"status": {"ok"}, "result": {`{"x":1}`}})
+	third := getNextSimulation(t, server.URL)
+
+	// === THEN ===
+	if first["status"] != "ok" || first["simulation_id"] != 1.0 {
+		t.Errorf("Got: '%v' - Expected simulation run 1", first)
+	}
+
+	if second["status"] != "wait" || second["duration_in_seconds"] != 3.0 {
+		t.Errorf("Got: '%v' - Expected wait for 3 seconds", second)
+	}
+
+	if third["status"] != "all_sent" {
+		t.Errorf("Got: '%v' - Expected all_sent", third)
+	}
+
+	completion, ok := fake.Completion("1", 1)
+	if !ok || completion.Values.Get("result") != `{"x":1}` {
+		t.Errorf("Got: '%v' - Expected recorded results", completion)
+	}
+}
+
+func TestNextSimulationShouldSendGivenBackSimulationRunAgain(t *testing.T) {
+	// === GIVEN ===
+	fake := NewServer(Experiment{
+		ID:              "1",
+		InputParameters: []map[string]interface{}{{"parameter1": 1}, {"parameter1": 2}},
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	first := getNextSimulation(t, server.URL)
+
+	// === WHEN ===
+	request, _ := http.NewRequest("DELETE", server.URL+"/experiments/1/simulations/1", nil)
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	second := getNextSimulation(t, server.URL)
+	third := getNextSimulation(t, server.URL)
+
+	// === THEN ===
+	if first["simulation_id"] != 1.0 || second["simulation_id"] != 1.0 {
+		t.Errorf("Got: '%v', '%v' - Expected simulation run 1 sent twice", first, second)
+	}
+
+	if third["simulation_id"] != 2.0 {
+		t.Errorf("Got: '%v' - Expected simulation run 2", third)
+	}
+}
+
+func TestServerShouldRejectIncorrectCredentials(t *testing.T) {
+	// === GIVEN ===
+	fake := NewServer(Experiment{ID: "1"})
+	fake.User = "user"
+	fake.Password = "pass"
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL+"/information/experiment_managers", nil)
+	request.SetBasicAuth("user", "wrong")
+
+	// === WHEN ===
+	resp, err := http.DefaultClient.Do(request)
+
+	// === THEN ===
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Got: %v - Expected 401", resp.StatusCode)
+	}
+}
+
+func TestLoadExperimentsShouldReadCodeBaseRelativeToDefinition(t *testing.T) {
+	// === GIVEN ===
+	dir, _ := ioutil.TempDir("", "fake_scalarm")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(dir+"/code_base.zip", []byte("zip"), 0644)
+	ioutil.WriteFile(dir+"/experiments.json", []byte(`[{"id":"1","code_base":"code_base.zip","input_parameters":[{"a":1}]}]`), 0644)
+
+	// === WHEN ===
+	experiments, err := LoadExperiments(dir + "/experiments.json")
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	if len(experiments) != 1 || string(experiments[0].CodeBase) != "zip" || len(experiments[0].InputParameters) != 1 {
+		t.Errorf("Got: '%+v' - Expected experiment with code base", experiments)
+	}
+}
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
 
@@ -31,28 +29,50 @@ func main() {
 	rootDirPath, _ := os.Getwd()
 	fmt.Printf("[SiM] working directory: %s\n", rootDirPath)
 
-	// 1. load config file
-	config, err := scalarmWorker.CreateSimulationManagerConfig("config.json")
-	if err != nil {
+	// 'run-local' subcommand executes simulation runs without Scalarm services
+	if len(os.Args) > 1 && os.Args[1] == "run-local" {
+		runLocal(rootDirPath, os.Args[2:])
+		return
+	}
+
+	// 'doctor' subcommand checks the environment instead of executing simulation runs
+	args := os.Args[1:]
+	doctor := len(args) > 0 && args[0] == "doctor"
+	if doctor {
+		args = args[1:]
+	}
+
+	// 1. load config from the config file, environment variables and flags
+	config, err := scalarmWorker.LoadSimulationManagerConfig(args, os.Getenv)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil && doctor {
+		fmt.Printf("[FAIL] Configuration: %v\n", err)
+		os.Exit(1)
+	} else if err != nil {
 		Fatal(err)
 	}
 
-	// 2. prepare HTTP client
-	var client *http.Client
-	tlsConfig := tls.Config{InsecureSkipVerify: config.InsecureSSL}
-
-	if config.ScalarmCertificatePath != "" {
-		CAPool := x509.NewCertPool()
-		severCert, err := ioutil.ReadFile(config.ScalarmCertificatePath)
-		if err != nil {
-			Fatal(fmt.Errorf("Could not load Scalarm certificate"))
+	// doctor reports problems with TLS and transport settings as failed checks
+	if doctor {
+		if !scalarmWorker.Doctor(config, os.Stdout) {
+			os.Exit(1)
 		}
-		CAPool.AppendCertsFromPEM(severCert)
+		return
+	}
 
-		tlsConfig.RootCAs = CAPool
+	// 2. prepare HTTP client
+	tlsConfig, err := scalarmWorker.NewTLSConfig(config)
+	if err != nil {
+		Fatal(err)
 	}
 
-	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tlsConfig}}
+	transport, err := scalarmWorker.NewHTTPTransport(config, tlsConfig)
+	if err != nil {
+		Fatal(err)
+	}
+
+	client := &http.Client{Transport: transport}
 
 	// 3. create simulation manager instance and run it
 	sim := scalarmWorker.SimulationManager{
@@ -61,5 +81,40 @@ func main() {
 		RootDirPath: rootDirPath,
 	}
 
-	sim.Run()
+	if err := sim.Run(); err != nil {
+		if err == scalarmWorker.ErrSimulationsLimitReached {
+			os.Exit(1)
+		} else if _, ok := err.(*scalarmWorker.ShutdownError); ok {
+			os.Exit(scalarmWorker.ExitCodeShutdown)
+		}
+		Fatal(err)
+	}
+}
+
+// runLocal executes simulation runs with input parameters from a file and writes their results to a local file
+func runLocal(rootDirPath string, args []string) {
+	flags := flag.NewFlagSet("scalarm_simulation_manager run-local", flag.ContinueOnError)
+	codeBasePath := flags.String("code_base", "", "code base zip archive or directory with adapters")
+	inputPath := flags.String("input", "", "JSON array or JSON lines file with input parameters of simulation runs")
+	resultsPath := flags.String("results", "results.jsonl", "JSON lines file the results are appended to")
+	monitoringInterval := flags.Int("monitoring_interval", 0, "interval in seconds of printing performance statistics")
+
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
+
+	if *codeBasePath == "" || *inputPath == "" {
+		Fatal(fmt.Errorf("Both -code_base and -input are required"))
+	}
+
+	sim := scalarmWorker.SimulationManager{
+		Config:      &scalarmWorker.SimulationManagerConfig{MonitoringInterval: *monitoringInterval},
+		RootDirPath: rootDirPath,
+	}
+
+	if err := sim.RunLocal(*codeBasePath, *inputPath, *resultsPath); err != nil {
+		Fatal(err)
+	}
 }
@@ -0,0 +1,132 @@
+package scalarmWorker
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+)
+
+// number of _stdout.txt lines sent to Experiment Manager when an adapter fails
+const reasonStdoutLines = 20
+
+// maximum size of the _stdout.txt tail which is read when looking for the last lines
+const stdoutTailBytes = 64 * 1024
+
+// AdapterError describes a failed execution of one of the code base adapters:
+// input_writer, executor, output_reader or progress_monitor
+type AdapterError struct {
+	Stage      string
+	ExitCode   int
+	Err        error
+	StdoutTail string
+}
+
+func (e *AdapterError) Error() string {
+	return fmt.Sprintf("'%s' failed with exit code %d (%v), last lines of _stdout.txt:\n%s",
+		e.Stage, e.ExitCode, e.Err, e.StdoutTail)
+}
+
+// NewAdapterError prints details of an adapter failure and collects them to be reported to Experiment Manager
+func NewAdapterError(stage string, cmd *exec.Cmd, err error, stdoutPath string) *AdapterError {
+	fmt.Printf("[SiM] An error occurred during '%s' execution.\n", stage)
+	fmt.Printf("[SiM] Please check if '%s' executes correctly on the selected infrastructure.\n", stage)
+	fmt.Printf("[Error] occured during '%v' execution \n", strings.Join(cmd.Args, " "))
+	fmt.Printf("[Error] %s\n", err.Error())
+	PrintStdoutLog(stdoutPath)
+
+	return &AdapterError{
+		Stage:      stage,
+		ExitCode:   ExitCode(err),
+		Err:        err,
+		StdoutTail: StdoutTail(stdoutPath, reasonStdoutLines),
+	}
+}
+
+// ExitCode returns the exit code of a finished command or -1 when the command has not exited on its own
+func ExitCode(err error) int {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Exited() {
+			return status.ExitStatus()
+		}
+	}
+
+	return -1
+}
+
+// RunAdapter executes an adapter script of the code base in the simulation run directory
+// with its output appended to _stdout.txt, nothing is done when the code base does not include the adapter
+func RunAdapter(stage, codeBaseDir, simulationDirPath, args string) error {
+	return RunAdapterContext(context.Background(), stage, codeBaseDir, simulationDirPath, args)
+}
+
+// RunAdapterContext works as RunAdapter, the whole process tree of the adapter is killed when ctx is done
+func RunAdapterContext(ctx context.Context, stage, codeBaseDir, simulationDirPath, args string) error {
+	adapterPath := path.Join(codeBaseDir, stage)
+	if _, err := os.Stat(adapterPath); err != nil {
+		return nil
+	}
+
+	fmt.Printf("[SiM] Before %s ...\n", stage)
+	cmd := exec.Command("sh", "-c", strings.TrimSpace(adapterPath+" "+args)+" >>_stdout.txt 2>&1")
+	cmd.Dir = simulationDirPath
+
+	if err := RunCommandContext(ctx, cmd); err != nil {
+		return NewAdapterError(stage, cmd, err, path.Join(simulationDirPath, "_stdout.txt"))
+	}
+	fmt.Printf("[SiM] After %s ...\n", stage)
+
+	return nil
+}
+
+// RunCommandContext runs the command in its own process group and kills the whole group when ctx is done,
+// unlike exec.CommandContext which kills only the command itself
+func RunCommandContext(ctx context.Context, cmd *exec.Cmd) error {
+	SetProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		SignalProcessGroup(cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return ctx.Err()
+	}
+}
+
+// StdoutTail returns at most linesNum last lines of the given file
+func StdoutTail(stdoutPath string, linesNum int) string {
+	file, err := os.Open(stdoutPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil && info.Size() > stdoutTailBytes {
+		file.Seek(-stdoutTailBytes, os.SEEK_END)
+	}
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(lines) > linesNum {
+		lines = lines[len(lines)-linesNum:]
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,83 @@
+package scalarmWorker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestRunAdapterShouldSkipMissingAdapter(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "scalarm_adapter_test")
+	defer os.RemoveAll(dir)
+
+	if err := RunAdapter("input_writer", dir, dir, "input.json"); err != nil {
+		t.Errorf("Got: '%v' - Expected nil", err)
+	}
+}
+
+func TestRunAdapterShouldReturnAdapterErrorWithExitCodeAndStdoutTail(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "scalarm_adapter_test")
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(path.Join(dir, "output_reader"), []byte("#!/bin/sh\necho first\necho second\nexit 7\n"), 0777)
+
+	err := RunAdapter("output_reader", dir, dir, "")
+
+	adapterErr, ok := err.(*AdapterError)
+	if !ok {
+		t.Fatalf("Got: '%v' - Expected AdapterError", err)
+	}
+
+	if adapterErr.Stage != "output_reader" {
+		t.Errorf("Got: '%v' - Expected 'output_reader'", adapterErr.Stage)
+	}
+
+	if adapterErr.ExitCode != 7 {
+		t.Errorf("Got: '%v' - Expected 7", adapterErr.ExitCode)
+	}
+
+	if adapterErr.StdoutTail != "first\nsecond" {
+		t.Errorf("Got: '%v' - Expected 'first\\nsecond'", adapterErr.StdoutTail)
+	}
+}
+
+func TestRunAdapterContextShouldKillAdapterProcessTreeWhenContextIsCanceled(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "scalarm_adapter_test")
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(path.Join(dir, "input_writer"), []byte("#!/bin/sh\nsleep 30 &\nwait\n"), 0777)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := RunAdapterContext(ctx, "input_writer", dir, dir, "input.json")
+
+	adapterErr, ok := err.(*AdapterError)
+	if !ok || adapterErr.Err != context.DeadlineExceeded {
+		t.Errorf("Got: '%v' - Expected AdapterError caused by '%v'", err, context.DeadlineExceeded)
+	}
+
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("Adapter has not been killed")
+	}
+}
+
+func TestStdoutTailShouldReturnLastLines(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "scalarm_adapter_test")
+	defer os.RemoveAll(dir)
+
+	stdoutPath := path.Join(dir, "_stdout.txt")
+	ioutil.WriteFile(stdoutPath, []byte("1\n2\n3\n4\n"), 0666)
+
+	if tail := StdoutTail(stdoutPath, 2); tail != "3\n4" {
+		t.Errorf("Got: '%v' - Expected '3\\n4'", tail)
+	}
+
+	if tail := StdoutTail(path.Join(dir, "missing.txt"), 2); tail != "" {
+		t.Errorf("Got: '%v' - Expected ''", tail)
+	}
+}
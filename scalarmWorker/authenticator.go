@@ -0,0 +1,112 @@
+package scalarmWorker
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// supported values of 'auth_method'
+const (
+	AuthMethodBasic            = "basic"
+	AuthMethodToken            = "token"
+	AuthMethodTokenFile        = "token_file"
+	AuthMethodProxyCertificate = "proxy_certificate"
+)
+
+// header carrying a base64 encoded X.509 proxy certificate
+const proxyCertificateHeader = "X-Proxy-Cert"
+
+// Authenticator adds credentials to requests sent to Scalarm services
+type Authenticator interface {
+	Authenticate(request *http.Request) error
+}
+
+// BasicAuthenticator authenticates with a user name and a password
+type BasicAuthenticator struct {
+	User     string
+	Password string
+}
+
+func (auth *BasicAuthenticator) Authenticate(request *http.Request) error {
+	request.SetBasicAuth(auth.User, auth.Password)
+	return nil
+}
+
+// TokenAuthenticator authenticates with a bearer token
+type TokenAuthenticator struct {
+	Token string
+}
+
+func (auth *TokenAuthenticator) Authenticate(request *http.Request) error {
+	request.Header.Set("Authorization", "Bearer "+auth.Token)
+	return nil
+}
+
+// TokenFileAuthenticator authenticates with a bearer token read from a file before every request,
+// so the token may be rotated while the worker is running
+type TokenFileAuthenticator struct {
+	Path string
+}
+
+func (auth *TokenFileAuthenticator) Authenticate(request *http.Request) error {
+	content, err := ioutil.ReadFile(auth.Path)
+	if err != nil {
+		return err
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return errors.New("Token file " + auth.Path + " is empty.")
+	}
+
+	request.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// ProxyCertificateAuthenticator authenticates with a Scalarm (X.509) proxy certificate read from a file
+// before every request, so the proxy may be renewed while the worker is running. Only certificates of the proxy
+// are sent, its private key, which grid proxy files usually contain, never leaves the node.
+type ProxyCertificateAuthenticator struct {
+	Path string
+}
+
+func (auth *ProxyCertificateAuthenticator) Authenticate(request *http.Request) error {
+	content, err := ioutil.ReadFile(auth.Path)
+	if err != nil {
+		return err
+	}
+
+	certificates := []byte{}
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			certificates = append(certificates, pem.EncodeToMemory(block)...)
+		}
+	}
+
+	if len(certificates) == 0 {
+		return errors.New("There is no PEM certificate in the proxy file " + auth.Path + ".")
+	}
+
+	request.Header.Set(proxyCertificateHeader, base64.StdEncoding.EncodeToString(certificates))
+	return nil
+}
+
+// NewAuthenticator creates the authenticator selected with 'auth_method', basic authentication is the default
+func NewAuthenticator(config *SimulationManagerConfig) (Authenticator, error) {
+	switch config.AuthMethod {
+	case "", AuthMethodBasic:
+		return &BasicAuthenticator{User: config.ExperimentManagerUser, Password: config.ExperimentManagerPass}, nil
+	case AuthMethodToken:
+		return &TokenAuthenticator{Token: config.AuthToken}, nil
+	case AuthMethodTokenFile:
+		return &TokenFileAuthenticator{Path: config.AuthTokenFile}, nil
+	case AuthMethodProxyCertificate:
+		return &ProxyCertificateAuthenticator{Path: config.ProxyCertificatePath}, nil
+	}
+
+	return nil, errors.New("Unsupported authentication method '" + config.AuthMethod + "'.")
+}
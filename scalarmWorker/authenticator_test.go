@@ -0,0 +1,103 @@
+package scalarmWorker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestScalarmClientShouldUseTokenRotatedInFile(t *testing.T) {
+	// === GIVEN ===
+	authorizations := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	tokenFile, _ := ioutil.TempFile("", "token")
+	defer os.Remove(tokenFile.Name())
+	ioutil.WriteFile(tokenFile.Name(), []byte("first\n"), 0600)
+
+	config := getSimConfig()
+	config.AuthMethod = AuthMethodTokenFile
+	config.AuthTokenFile = tokenFile.Name()
+
+	client := &ScalarmClient{HttpClient: getHttpClientMock(server.URL), Endpoints: NewEndpointPool([]string{"em.scalarm.com"}),
+		Config: config, Timeout: time.Second}
+	request := ScalarmRequest{Method: "GET", ServiceMethod: "experiments/random_experiment"}
+
+	// === WHEN ===
+	client.Read(context.Background(), request)
+	ioutil.WriteFile(tokenFile.Name(), []byte("second"), 0600)
+	client.Read(context.Background(), request)
+
+	// === THEN ===
+	if len(authorizations) != 2 || authorizations[0] != "Bearer first" || authorizations[1] != "Bearer second" {
+		t.Errorf("Got: '%v' - Expected '[Bearer first Bearer second]'", authorizations)
+	}
+}
+
+func TestProxyCertificateAuthenticatorShouldSendEncodedCertificate(t *testing.T) {
+	// === GIVEN ===
+	proxyFile, _ := ioutil.TempFile("", "proxy")
+	defer os.Remove(proxyFile.Name())
+	proxyCertificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("proxy")})
+	ioutil.WriteFile(proxyFile.Name(), proxyCertificate, 0600)
+
+	config := &SimulationManagerConfig{AuthMethod: AuthMethodProxyCertificate, ProxyCertificatePath: proxyFile.Name()}
+	request, _ := http.NewRequest("GET", "https://em.scalarm.com/experiments", nil)
+
+	// === WHEN ===
+	authenticator, err := NewAuthenticator(config)
+	if err == nil {
+		err = authenticator.Authenticate(request)
+	}
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	decoded, _ := base64.StdEncoding.DecodeString(request.Header.Get("X-Proxy-Cert"))
+	if string(decoded) != string(proxyCertificate) {
+		t.Errorf("Got: '%s' - Expected the proxy certificate", decoded)
+	}
+
+	if _, _, ok := request.BasicAuth(); ok {
+		t.Errorf("Got: basic auth - Expected only the proxy certificate")
+	}
+}
+
+func TestProxyCertificateAuthenticatorShouldNotSendPrivateKeyOfProxy(t *testing.T) {
+	// === GIVEN ===
+	proxyCertificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("proxy")})
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("secret")})
+	userCertificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("user")})
+
+	// grid proxy files keep the proxy certificate, its private key and the certificate of the user
+	proxyFile, _ := ioutil.TempFile("", "proxy")
+	defer os.Remove(proxyFile.Name())
+	ioutil.WriteFile(proxyFile.Name(), append(append(append([]byte{}, proxyCertificate...), privateKey...), userCertificate...), 0600)
+
+	authenticator := &ProxyCertificateAuthenticator{Path: proxyFile.Name()}
+	request, _ := http.NewRequest("GET", "https://em.scalarm.com/experiments", nil)
+
+	// === WHEN ===
+	err := authenticator.Authenticate(request)
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	decoded, _ := base64.StdEncoding.DecodeString(request.Header.Get("X-Proxy-Cert"))
+	if expected := string(proxyCertificate) + string(userCertificate); string(decoded) != expected {
+		t.Errorf("Got: '%s' - Expected '%s'", decoded, expected)
+	}
+}
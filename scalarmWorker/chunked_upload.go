@@ -0,0 +1,201 @@
+package scalarmWorker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// chunked uploads send a file to Storage Manager in parts, so a dropped connection resends only the current chunk:
+//   POST experiments/:id/simulations/:index/uploads with file_name, size and checksum (SHA-256 of the whole file)
+//     starts an upload or returns the existing one of the same file: {"status":"ok","upload_id":..,"offset":..,"chunk_size":..}
+//   PUT  .../uploads/:upload_id/chunks/:offset with the chunk as the body and its SHA-256 in the Digest header
+//     returns the acknowledged offset: {"status":"ok","offset":..}
+//   GET  .../uploads/:upload_id returns the acknowledged offset, it is used to resume after a failed chunk
+//   POST .../uploads/:upload_id/complete verifies the checksum and stores the file as the binary output
+// Storage Manager answering 404, 405 or 501 to the first request does not support chunked uploads.
+
+// defaultUploadChunkSize is used when Storage Manager does not give the chunk size
+const defaultUploadChunkSize = 8 * 1024 * 1024
+
+// maxUploadResumes is the number of consecutive failed chunks after which the upload is given up
+const maxUploadResumes = 5
+
+// errChunkedUploadUnsupported is returned when Storage Manager does not support chunked uploads
+var errChunkedUploadUnsupported = errors.New("Storage manager does not support chunked uploads")
+
+// uploadSession is the answer of Storage Manager describing a chunked upload
+type uploadSession struct {
+	StatusResponse
+	UploadID  string `json:"upload_id"`
+	Offset    int64  `json:"offset"`
+	ChunkSize int64  `json:"chunk_size"`
+}
+
+// uploadChunked sends the file named fileName in chunks starting from the offset acknowledged by Storage Manager,
+// it returns errChunkedUploadUnsupported when Storage Manager does not support chunked uploads
+func (sm *StorageManager) uploadChunked(ctx context.Context, simulationPath, filePath, fileName string, size int64) error {
+	checksum, err := fileChecksum(filePath)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{}
+	form.Set("file_name", fileName)
+	form.Set("size", strconv.FormatInt(size, 10))
+	form.Set("checksum", checksum)
+
+	var session uploadSession
+	err = sm.client().ReadJSON(ctx, NewFormRequest("POST", simulationPath+"/uploads", form), &session)
+	if statusErr, ok := err.(*HTTPStatusError); ok && chunkedUploadUnsupported(statusErr.StatusCode) {
+		return errChunkedUploadUnsupported
+	} else if err != nil {
+		return err
+	} else if err = session.Err(); err != nil {
+		return err
+	}
+
+	chunkSize := session.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultUploadChunkSize
+	}
+
+	uploadPath := simulationPath + "/uploads/" + session.UploadID
+	offset := session.Offset
+	if offset > size {
+		return acknowledgedBeyond(fileName, offset, size)
+	} else if offset > 0 {
+		fmt.Printf("[SiM] Resuming upload of '%s' from %v of %v bytes\n", fileName, offset, size)
+	}
+
+	policy := NewRetryPolicy(sm.Config)
+	for failures := 0; offset < size; {
+		length := chunkSize
+		if size-offset < length {
+			length = size - offset
+		}
+
+		acknowledged, err := sm.sendChunk(ctx, uploadPath, FileSectionBody{Path: filePath, Offset: offset, Length: length})
+		if err == nil && acknowledged > offset+length {
+			return acknowledgedBeyond(fileName, acknowledged, offset+length)
+		} else if err == nil && acknowledged > offset {
+			failures = 0
+			offset = acknowledged
+			fmt.Printf("[SiM] Uploaded %v of %v bytes of '%s'\n", offset, size, fileName)
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if failures++; failures > maxUploadResumes {
+			if err == nil {
+				err = fmt.Errorf("Storage manager did not acknowledge chunk at offset %v", offset)
+			}
+			return err
+		}
+
+		fmt.Printf("[SiM] Chunk at offset %v of '%s' failed: %v, resuming\n", offset, fileName, err)
+		if err := policy.sleep(ctx, policy.Backoff(failures)); err != nil {
+			return err
+		}
+
+		// the chunk could have been stored even though its acknowledgement was lost
+		if acknowledged, err := sm.acknowledgedOffset(ctx, uploadPath); err == nil && acknowledged > size {
+			return acknowledgedBeyond(fileName, acknowledged, size)
+		} else if err == nil {
+			offset = acknowledged
+		}
+	}
+
+	var completed StatusResponse
+	if err := sm.client().ReadJSON(ctx, ScalarmRequest{Method: "POST", ServiceMethod: uploadPath + "/complete"}, &completed); err != nil {
+		return err
+	}
+
+	return completed.Err()
+}
+
+// sendChunk uploads the chunk with its checksum and returns the offset acknowledged by Storage Manager
+func (sm *StorageManager) sendChunk(ctx context.Context, uploadPath string, chunk FileSectionBody) (int64, error) {
+	digest, err := chunkDigest(chunk)
+	if err != nil {
+		return 0, err
+	}
+
+	request := ScalarmRequest{
+		Method:        "PUT",
+		ServiceMethod: uploadPath + "/chunks/" + strconv.FormatInt(chunk.Offset, 10),
+		Body:          chunk,
+		ContentType:   "application/octet-stream",
+		Header:        http.Header{"Digest": {digest}},
+	}
+
+	var session uploadSession
+	if err := sm.client().ReadJSON(ctx, request, &session); err != nil {
+		return 0, err
+	}
+
+	return session.Offset, session.Err()
+}
+
+// acknowledgedOffset asks Storage Manager how much of the file has been stored
+func (sm *StorageManager) acknowledgedOffset(ctx context.Context, uploadPath string) (int64, error) {
+	var session uploadSession
+	if err := sm.client().ReadJSON(ctx, ScalarmRequest{Method: "GET", ServiceMethod: uploadPath}, &session); err != nil {
+		return 0, err
+	}
+
+	return session.Offset, session.Err()
+}
+
+// acknowledgedBeyond returns the protocol error of Storage Manager acknowledging bytes which were not sent
+func acknowledgedBeyond(fileName string, acknowledged, sent int64) error {
+	return fmt.Errorf("Storage manager acknowledged %v bytes of '%s' while only %v were sent", acknowledged, fileName, sent)
+}
+
+func chunkedUploadUnsupported(statusCode int) bool {
+	return statusCode == http.StatusNotFound || statusCode == http.StatusMethodNotAllowed ||
+		statusCode == http.StatusNotImplemented
+}
+
+// fileChecksum returns hex encoded SHA-256 of the file
+func fileChecksum(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// chunkDigest returns the value of the Digest header (RFC 3230) of the chunk
+func chunkDigest(chunk FileSectionBody) (string, error) {
+	reader, err := chunk.Open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(hash.Sum(nil)), nil
+}
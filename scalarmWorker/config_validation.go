@@ -0,0 +1,269 @@
+package scalarmWorker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConfigFieldError describes a problem with a single config value
+type ConfigFieldError struct {
+	Field   string
+	Problem string
+}
+
+func (e ConfigFieldError) Error() string {
+	return e.Field + ": " + e.Problem
+}
+
+// ConfigValidationError aggregates all problems found in the config
+type ConfigValidationError struct {
+	Errors []ConfigFieldError
+}
+
+func (e *ConfigValidationError) Error() string {
+	problems := make([]string, len(e.Errors))
+	for i, fieldError := range e.Errors {
+		problems[i] = "- " + fieldError.Error()
+	}
+
+	return "Incorrect config:\n" + strings.Join(problems, "\n")
+}
+
+func (e *ConfigValidationError) add(field, problem string) {
+	e.Errors = append(e.Errors, ConfigFieldError{Field: field, Problem: problem})
+}
+
+// Validate checks all config values and returns ConfigValidationError listing every problem found
+func (config *SimulationManagerConfig) Validate() error {
+	validationErr := new(ConfigValidationError)
+
+	if config.InformationServiceUrl == "" {
+		if config.UsesInformationService() {
+			validationErr.add("information_service_url", "is required")
+		}
+	} else if err := validateServiceURL(config.InformationServiceUrl); err != nil {
+		validationErr.add("information_service_url", err.Error())
+	}
+
+	staticUrls := []struct {
+		field string
+		urls  []string
+	}{
+		{"experiment_manager_urls", config.ExperimentManagerUrls},
+		{"storage_manager_urls", config.StorageManagerUrls},
+	}
+
+	for _, static := range staticUrls {
+		for _, serviceURL := range static.urls {
+			if err := validateServiceURL(serviceURL); err != nil {
+				validationErr.add(static.field, "'"+serviceURL+"' "+err.Error())
+			}
+		}
+	}
+
+	switch config.AuthMethod {
+	case "", AuthMethodBasic:
+		if config.ExperimentManagerUser == "" {
+			validationErr.add("experiment_manager_user", "is required")
+		}
+
+		if config.ExperimentManagerPass == "" {
+			validationErr.add("experiment_manager_pass", "is required")
+		}
+	case AuthMethodToken:
+		if config.AuthToken == "" {
+			validationErr.add("auth_token", "is required")
+		}
+	case AuthMethodTokenFile:
+		if config.AuthTokenFile == "" {
+			validationErr.add("auth_token_file", "is required")
+		} else if _, err := os.Stat(config.AuthTokenFile); err != nil {
+			validationErr.add("auth_token_file", "file does not exist: "+config.AuthTokenFile)
+		}
+	case AuthMethodProxyCertificate:
+		if config.ProxyCertificatePath == "" {
+			validationErr.add("proxy_certificate_path", "is required")
+		} else if _, err := os.Stat(config.ProxyCertificatePath); err != nil {
+			validationErr.add("proxy_certificate_path", "file does not exist: "+config.ProxyCertificatePath)
+		}
+	default:
+		validationErr.add("auth_method", "must be one of: basic, token, token_file, proxy_certificate")
+	}
+
+	if config.StartAt != "" {
+		if _, err := time.Parse(time.RFC3339, config.StartAt); err != nil {
+			validationErr.add("start_at", "is not a time in RFC3339 format: "+err.Error())
+		}
+	}
+
+	if config.Deadline != "" {
+		if _, err := ParseDeadline(config.Deadline, time.Now()); err != nil {
+			validationErr.add("deadline", "is neither a time in RFC3339 format nor a duration")
+		}
+	}
+
+	if config.ScalarmCertificatePath != "" {
+		if _, err := os.Stat(config.ScalarmCertificatePath); err != nil {
+			validationErr.add("scalarm_certificate_path", "file does not exist: "+config.ScalarmCertificatePath)
+		}
+	}
+
+	for _, caFile := range config.CACertificatePaths {
+		if _, err := os.Stat(caFile); err != nil {
+			validationErr.add("ca_certificate_paths", "file does not exist: "+caFile)
+		}
+	}
+
+	if config.CACertificateDir != "" {
+		if info, err := os.Stat(config.CACertificateDir); err != nil || !info.IsDir() {
+			validationErr.add("ca_certificate_dir", "directory does not exist: "+config.CACertificateDir)
+		}
+	}
+
+	if config.ClientCertificatePath != "" && config.ClientKeyPath == "" {
+		validationErr.add("client_key_path", "is required with client_certificate_path")
+	} else if config.ClientCertificatePath == "" && config.ClientKeyPath != "" {
+		validationErr.add("client_certificate_path", "is required with client_key_path")
+	}
+
+	if _, ok := tlsVersions[config.TLSMinVersion]; config.TLSMinVersion != "" && !ok {
+		validationErr.add("tls_min_version", "must be one of: 1.0, 1.1, 1.2, 1.3")
+	}
+
+	if config.ProxyURL != "" && config.ProxyURL != proxyNone {
+		if _, err := parseProxyURL(config.ProxyURL); err != nil {
+			validationErr.add("proxy_url", err.Error())
+		}
+	}
+
+	if config.SimulationsLimit < -1 {
+		validationErr.add("simulations_limit", "must be -1 (unlimited) or non-negative")
+	}
+
+	nonNegative := []struct {
+		field string
+		value int
+	}{
+		{"timeout", config.Timeout},
+		{"monitoring_interval", config.MonitoringInterval},
+		{"cooldown_interval", config.CooldownInterval},
+		{"parallel_slots", config.ParallelSlots},
+		{"max_consecutive_failures", config.MaxConsecutiveFailures},
+		{"shutdown_grace_period", config.ShutdownGracePeriod},
+		{"retry_max_attempts", config.RetryMaxAttempts},
+		{"retry_initial_backoff", config.RetryInitialBackoff},
+		{"retry_max_backoff", config.RetryMaxBackoff},
+		{"managers_refresh_interval", config.ManagersRefreshInterval},
+		{"information_service_cache_max_age", config.InformationServiceCacheMaxAge},
+		{"connect_timeout", config.ConnectTimeout},
+		{"tls_handshake_timeout", config.TLSHandshakeTimeout},
+		{"response_header_timeout", config.ResponseHeaderTimeout},
+		{"keep_alive", config.KeepAlive},
+		{"max_idle_connections", config.MaxIdleConnections},
+		{"max_idle_connections_per_host", config.MaxIdleConnectionsPerHost},
+		{"idle_connection_timeout", config.IdleConnectionTimeout},
+		{"upload_size_limit", config.UploadSizeLimit},
+		{"outbox_max_attempts", config.OutboxMaxAttempts},
+		{"outbox_max_age", config.OutboxMaxAge},
+		{"outbox_drain_timeout", config.OutboxDrainTimeout},
+	}
+
+	for _, value := range nonNegative {
+		if value.value < 0 {
+			validationErr.add(value.field, "must not be negative")
+		}
+	}
+
+	for _, code := range config.RetryStatusCodes {
+		if code < 100 || code > 599 {
+			validationErr.add("retry_status_codes", fmt.Sprintf("%v is not a HTTP response code", code))
+		}
+	}
+
+	if len(validationErr.Errors) > 0 {
+		return validationErr
+	}
+
+	return nil
+}
+
+// validateServiceURL accepts service addresses with or without scheme, e.g. 'scalarm.com:11300/information'
+func validateServiceURL(serviceURL string) error {
+	_, err := parseServiceAddress(serviceURL, false)
+	return err
+}
+
+// decodeConfig decodes the config file reporting all unknown keys and incorrect values at once,
+// syntax errors are reported with the line and column where they occurred
+func decodeConfig(content []byte, config *SimulationManagerConfig) error {
+	values := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(content, &values); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			// the offset points just after the offending character unless the input ended unexpectedly
+			offset := syntaxErr.Offset
+			if offset > 0 && offset < int64(len(content)) {
+				offset--
+			}
+			line, column := position(content, offset)
+			return fmt.Errorf("Incorrect JSON in the file at line %d, column %d: %v", line, column, syntaxErr)
+		}
+
+		return fmt.Errorf("Incorrect JSON in the file: %v", err)
+	}
+
+	validationErr := new(ConfigValidationError)
+	configValue := reflect.ValueOf(config).Elem()
+
+	for i := 0; i < configValue.NumField(); i++ {
+		key := configValue.Type().Field(i).Tag.Get("json")
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		delete(values, key)
+
+		if err := json.Unmarshal(value, configValue.Field(i).Addr().Interface()); err != nil {
+			if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+				validationErr.add(key, fmt.Sprintf("expected %v but got %v", typeErr.Type, typeErr.Value))
+			} else {
+				validationErr.add(key, err.Error())
+			}
+		}
+	}
+
+	unknownKeys := []string{}
+	for key := range values {
+		unknownKeys = append(unknownKeys, key)
+	}
+	sort.Strings(unknownKeys)
+
+	for _, key := range unknownKeys {
+		validationErr.add(key, "unknown key")
+	}
+
+	if len(validationErr.Errors) > 0 {
+		return validationErr
+	}
+
+	return nil
+}
+
+// position returns the line and column of the given offset in the content, both starting from 1
+func position(content []byte, offset int64) (int, int) {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+
+	preceding := content[:offset]
+	line := bytes.Count(preceding, []byte("\n")) + 1
+	column := len(preceding) - bytes.LastIndex(preceding, []byte("\n"))
+
+	return line, column
+}
@@ -0,0 +1,184 @@
+package scalarmWorker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateShouldReturnAllProblemsAtOnce(t *testing.T) {
+	// === GIVEN ===
+	config := &SimulationManagerConfig{
+		ExperimentManagerUser:  "user",
+		StartAt:                "tomorrow",
+		ScalarmCertificatePath: "test_assets/does_not_exist.pem",
+		CooldownInterval:       -5,
+	}
+
+	// === WHEN ===
+	err := config.Validate()
+
+	// === THEN ===
+	expected_msg := "Incorrect config:\n" +
+		"- information_service_url: is required\n" +
+		"- experiment_manager_pass: is required\n" +
+		"- start_at: is not a time in RFC3339 format: parsing time \"tomorrow\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"tomorrow\" as \"2006\"\n" +
+		"- scalarm_certificate_path: file does not exist: test_assets/does_not_exist.pem\n" +
+		"- cooldown_interval: must not be negative"
+
+	if err == nil || err.Error() != expected_msg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expected_msg)
+	}
+}
+
+func TestValidateShouldRejectInformationServiceUrlWithUnsupportedScheme(t *testing.T) {
+	// === GIVEN ===
+	config := &SimulationManagerConfig{
+		InformationServiceUrl: "ftp://scalarm.com/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+	}
+
+	// === WHEN ===
+	err := config.Validate()
+
+	// === THEN ===
+	validationErr, ok := err.(*ConfigValidationError)
+	if !ok || len(validationErr.Errors) != 1 || validationErr.Errors[0].Field != "information_service_url" {
+		t.Errorf("Got: '%v' - Expected single information_service_url error", err)
+	}
+}
+
+func TestValidateShouldRejectSimulationsLimitBelowUnlimited(t *testing.T) {
+	// === GIVEN ===
+	config := &SimulationManagerConfig{
+		InformationServiceUrl: "https://scalarm.com/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		SimulationsLimit:      -2,
+	}
+
+	// === WHEN ===
+	err := config.Validate()
+
+	// === THEN ===
+	expected_msg := "Incorrect config:\n- simulations_limit: must be -1 (unlimited) or non-negative"
+
+	if err == nil || err.Error() != expected_msg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expected_msg)
+	}
+}
+
+func TestValidateShouldAcceptCorrectConfig(t *testing.T) {
+	// === GIVEN ===
+	config := &SimulationManagerConfig{
+		InformationServiceUrl: "scalarm.com:11300/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		StartAt:               "2016-01-02T15:04:05Z",
+		Deadline:              "2h",
+		SimulationsLimit:      -1,
+	}
+
+	// === WHEN ===
+	err := config.Validate()
+
+	// === THEN ===
+	if err != nil {
+		t.Errorf("Got: '%v' - Expected nil", err)
+	}
+}
+
+func TestValidateShouldNotRequireInformationServiceWhenManagersAreGiven(t *testing.T) {
+	// === GIVEN ===
+	config := &SimulationManagerConfig{
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		ExperimentManagerUrls: []string{"em.scalarm.com", "ftp://em2.scalarm.com"},
+		StorageManagerUrls:    []string{"sm.scalarm.com"},
+	}
+
+	// === WHEN ===
+	err := config.Validate()
+
+	// === THEN ===
+	expected_msg := "Incorrect config:\n" +
+		"- experiment_manager_urls: 'ftp://em2.scalarm.com' has unsupported scheme 'ftp'"
+
+	if err == nil || err.Error() != expected_msg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expected_msg)
+	}
+}
+
+func TestValidateShouldRequireCredentialsOfSelectedAuthMethod(t *testing.T) {
+	// === GIVEN ===
+	config := &SimulationManagerConfig{
+		InformationServiceUrl: "scalarm.com/information",
+		AuthMethod:            AuthMethodTokenFile,
+		AuthTokenFile:         "test_assets/does_not_exist.token",
+	}
+
+	// === WHEN ===
+	err := config.Validate()
+
+	// === THEN ===
+	expected_msg := "Incorrect config:\n" +
+		"- auth_token_file: file does not exist: test_assets/does_not_exist.token"
+
+	if err == nil || err.Error() != expected_msg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expected_msg)
+	}
+}
+
+func TestReadingConfigFileShouldReportUnknownKeysAndIncorrectTypes(t *testing.T) {
+	// === GIVEN ===
+	path := writeConfigFile(t, `{
+  "information_service_url": "127.0.0.1:11300",
+  "timeout": "60",
+  "experiment_manager_password": "secret",
+  "insecure": true
+}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	// === WHEN ===
+	err := readConfigFile(path, new(SimulationManagerConfig))
+
+	// === THEN ===
+	expected_msg := "Incorrect config:\n" +
+		"- timeout: expected int but got string\n" +
+		"- experiment_manager_password: unknown key\n" +
+		"- insecure: unknown key"
+
+	if err == nil || err.Error() != expected_msg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expected_msg)
+	}
+}
+
+func TestReadingConfigFileShouldReportLineAndColumnOfSyntaxError(t *testing.T) {
+	// === GIVEN ===
+	path := writeConfigFile(t, "{\n  \"timeout\": 60\n  \"development\": true\n}")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	// === WHEN ===
+	err := readConfigFile(path, new(SimulationManagerConfig))
+
+	// === THEN ===
+	expected_msg := "Incorrect JSON in the file at line 3, column 3: invalid character '\"' after object key:value pair"
+
+	if err == nil || err.Error() != expected_msg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expected_msg)
+	}
+}
@@ -0,0 +1,39 @@
+package scalarmWorker
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ParseDeadline parses the deadline option given either as an absolute time in RFC3339 format
+// or as a duration (e.g. "11h30m") counted from now
+func ParseDeadline(deadline string, now time.Time) (time.Time, error) {
+	if deadlineTime, err := time.Parse(time.RFC3339, deadline); err == nil {
+		return deadlineTime, nil
+	}
+
+	if duration, err := time.ParseDuration(deadline); err == nil {
+		return now.Add(duration), nil
+	}
+
+	return time.Time{}, errors.New("Incorrect deadline '" + deadline + "', expected RFC3339 time or duration.")
+}
+
+// DetectDeadline returns the end of the batch job allocation the worker is running in,
+// based on environment variables set by the queuing system; with PBS the worker is assumed to start with the job
+func DetectDeadline(getenv func(string) string, now time.Time) (time.Time, bool) {
+	// SLURM provides the end of the job as a unix timestamp
+	if endTime, err := strconv.ParseInt(getenv("SLURM_JOB_END_TIME"), 10, 64); err == nil && endTime > 0 {
+		return time.Unix(endTime, 0), true
+	}
+
+	// PBS provides only the walltime of the job in seconds, the job start time is not available in the environment,
+	// so the walltime is counted from the worker start; it is the deadline option which should be used
+	// when the worker is not started at the beginning of the job
+	if walltime, err := strconv.ParseInt(getenv("PBS_WALLTIME"), 10, 64); err == nil && walltime > 0 {
+		return now.Add(time.Duration(walltime) * time.Second), true
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,96 @@
+package scalarmWorker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDeadlineShouldHandleTimeAndDuration(t *testing.T) {
+	now := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	deadline, err := ParseDeadline("2017-04-01T20:00:00Z", now)
+	if err != nil || !deadline.Equal(time.Date(2017, 4, 1, 20, 0, 0, 0, time.UTC)) {
+		t.Errorf("Got: '%v', '%v' - Expected '2017-04-01T20:00:00Z'", deadline, err)
+	}
+
+	deadline, err = ParseDeadline("1h30m", now)
+	if err != nil || !deadline.Equal(now.Add(90*time.Minute)) {
+		t.Errorf("Got: '%v', '%v' - Expected '%v'", deadline, err, now.Add(90*time.Minute))
+	}
+
+	_, err = ParseDeadline("tomorrow", now)
+	expectedMsg := "Incorrect deadline 'tomorrow', expected RFC3339 time or duration."
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expectedMsg)
+	}
+}
+
+func TestDetectDeadlineShouldUseQueuingSystemEnvironment(t *testing.T) {
+	now := time.Unix(1491000000, 0)
+
+	env := map[string]string{"SLURM_JOB_END_TIME": "1491003600"}
+	deadline, ok := DetectDeadline(func(key string) string { return env[key] }, now)
+	if !ok || deadline.Unix() != 1491003600 {
+		t.Errorf("Got: '%v', '%v' - Expected SLURM job end time", deadline, ok)
+	}
+
+	env = map[string]string{"PBS_WALLTIME": "7200"}
+	deadline, ok = DetectDeadline(func(key string) string { return env[key] }, now)
+	if !ok || !deadline.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("Got: '%v', '%v' - Expected PBS walltime counted from now", deadline, ok)
+	}
+
+	env = map[string]string{}
+	if _, ok = DetectDeadline(func(key string) string { return env[key] }, now); ok {
+		t.Errorf("Got: deadline - Expected no deadline without queuing system variables")
+	}
+}
+
+func TestExperimentRunShouldNotStartSimulationRunExceedingDeadline(t *testing.T) {
+	now := time.Unix(1491000000, 0)
+	run := &experimentRun{}
+
+	if !run.canStartBefore(time.Time{}, now) {
+		t.Errorf("Simulation run should be started when there is no deadline")
+	}
+
+	if !run.canStartBefore(now.Add(time.Minute), now) {
+		t.Errorf("Simulation run should be started when nothing is known about its duration")
+	}
+
+	run.recordDuration(30*time.Second, 10*time.Minute)
+	run.recordDuration(50*time.Second, 10*time.Minute)
+
+	if run.expectedDuration() != 40*time.Second {
+		t.Errorf("Got: '%v' - Expected average duration '40s'", run.expectedDuration())
+	}
+
+	if !run.canStartBefore(now.Add(time.Minute), now) {
+		t.Errorf("Simulation run should be started when its average duration fits before the deadline")
+	}
+
+	if run.canStartBefore(now.Add(30*time.Second), now) {
+		t.Errorf("Simulation run should not be started when its average duration exceeds the deadline")
+	}
+}
+
+func TestSimulationRunShouldNotFinishBeforeDeadlineShorterThanItsTimeConstraint(t *testing.T) {
+	now := time.Unix(1491000000, 0)
+	simulationRun := &SimulationRunConfig{Status: "ok", ExecutionConstraints: ExecutionConstraints{TimeConstraintInSec: 600}}
+
+	if !simulationRun.CanFinishBefore(time.Time{}, now) {
+		t.Errorf("Simulation run should finish when there is no deadline")
+	}
+
+	if !simulationRun.CanFinishBefore(now.Add(time.Hour), now) {
+		t.Errorf("Simulation run should finish when its time constraint fits before the deadline")
+	}
+
+	if simulationRun.CanFinishBefore(now.Add(time.Minute), now) {
+		t.Errorf("Simulation run should not finish when its time constraint exceeds the deadline")
+	}
+
+	if !(&SimulationRunConfig{Status: "ok"}).CanFinishBefore(now.Add(time.Minute), now) {
+		t.Errorf("Simulation run without time constraint should always be started")
+	}
+}
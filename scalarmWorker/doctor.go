@@ -0,0 +1,232 @@
+package scalarmWorker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+// adapters which may be provided in the code base apart from the executor
+var optionalAdapters = []string{"input_writer", "output_reader", "progress_monitor"}
+
+// doctorReport prints results of the environment checks as they are done
+type doctorReport struct {
+	out    io.Writer
+	failed int
+}
+
+func (report *doctorReport) check(name, details string, err error) bool {
+	if err != nil {
+		report.failed++
+		fmt.Fprintf(report.out, "[FAIL] %s: %v\n", name, err)
+		return false
+	}
+
+	fmt.Fprintf(report.out, "[PASS] %s: %s\n", name, details)
+	return true
+}
+
+func (report *doctorReport) skip(names ...string) {
+	for _, name := range names {
+		fmt.Fprintf(report.out, "[SKIP] %s\n", name)
+	}
+}
+
+// finish prints the summary and returns false when any of the checks failed
+func (report *doctorReport) finish() bool {
+	if report.failed > 0 {
+		fmt.Fprintf(report.out, "%v check(s) failed\n", report.failed)
+		return false
+	}
+
+	fmt.Fprintln(report.out, "All checks passed")
+	return true
+}
+
+// Doctor checks if simulation runs of the configured experiment can be executed in the current environment:
+// it loads TLS certificates and creates the HTTP transport from the config, reaches the Information Service,
+// resolves Experiment and Storage Managers, verifies credentials, downloads the code base into a temporary
+// directory and checks its adapters. When the transport cannot be created, the remaining checks use proxies
+// from environment variables instead of 'proxy_url'.
+// It prints a report to out and returns false when any of the checks failed.
+func Doctor(config *SimulationManagerConfig, out io.Writer) bool {
+	report := &doctorReport{out: out}
+	report.check("Configuration", "loaded and valid", nil)
+
+	tlsConfig, err := NewTLSConfig(config)
+	if !report.check("TLS", "certificates loaded", err) {
+		report.skip("HTTP transport", "Experiment Managers", "Storage Managers", "Credentials", "Code base", "Adapters")
+		return report.finish()
+	}
+
+	transport, err := NewHTTPTransport(config, tlsConfig)
+	if !report.check("HTTP transport", "created", err) {
+		fallbackConfig := *config
+		fallbackConfig.ProxyURL = ""
+		if transport, err = NewHTTPTransport(&fallbackConfig, tlsConfig); err != nil {
+			report.skip("Experiment Managers", "Storage Managers", "Credentials", "Code base", "Adapters")
+			return report.finish()
+		}
+		fmt.Fprintln(out, "[INFO] Remaining checks use proxies from HTTPS_PROXY, HTTP_PROXY and NO_PROXY")
+	}
+
+	return report.checkServices(config, &http.Client{Transport: transport})
+}
+
+// checkServices runs checks of Scalarm services, the code base and adapters, then prints the summary
+func (report *doctorReport) checkServices(config *SimulationManagerConfig, client *http.Client) bool {
+	communicationTimeout := time.Duration(config.Timeout) * time.Second
+
+	// 1. Information Service
+	is := InformationService{
+		HttpClient:           client,
+		BaseUrl:              config.InformationServiceUrl,
+		CommunicationTimeout: communicationTimeout,
+		Config:               config}
+
+	experimentManagerUrls, err := is.GetExperimentManagers()
+	emResolved := report.check("Experiment Managers", strings.Join(experimentManagerUrls, ", "), err)
+	experimentManagers := NewEndpointPool(experimentManagerUrls)
+
+	storageManagers, err := is.GetStorageManagers()
+	report.check("Storage Managers", strings.Join(storageManagers, ", "), err)
+
+	if !emResolved {
+		report.skip("Credentials", "Code base", "Adapters")
+		return report.finish()
+	}
+
+	// 2. credentials are verified against the experiment with downloading its code base
+	experimentID := config.ExperimentId
+	if experimentID == "" {
+		body, err := doctorRequest("experiments/random_experiment", experimentManagers, config, client, communicationTimeout)
+		if err == nil && len(body) == 0 {
+			err = errors.New("There is no experiment available for the user.")
+		}
+		experimentID = string(body)
+
+		if !report.check("Credentials", "random experiment "+experimentID, err) {
+			report.skip("Code base", "Adapters")
+			return report.finish()
+		}
+	}
+
+	codeBase, err := doctorRequest("experiments/"+experimentID+"/code_base", experimentManagers, config, client, communicationTimeout)
+	if config.ExperimentId != "" {
+		var credentialsErr error
+		if _, ok := err.(*credentialsError); ok {
+			credentialsErr = err
+		}
+
+		if !report.check("Credentials", "access to experiment "+experimentID, credentialsErr) {
+			report.skip("Code base", "Adapters")
+			return report.finish()
+		}
+	}
+
+	// 3. code base is extracted into a temporary directory the same way as before executing simulation runs
+	codeBaseDir, tempDirErr := ioutil.TempDir("", "scalarm_doctor")
+	if tempDirErr != nil {
+		report.check("Code base", "", tempDirErr)
+		report.skip("Adapters")
+		return report.finish()
+	}
+	defer os.RemoveAll(codeBaseDir)
+
+	if err == nil {
+		err = ioutil.WriteFile(path.Join(codeBaseDir, "code_base.zip"), codeBase, 0600)
+	}
+	if err == nil {
+		err = Extract(path.Join(codeBaseDir, "code_base.zip"), codeBaseDir)
+	}
+	if err == nil {
+		err = Extract(path.Join(codeBaseDir, "simulation_binaries.zip"), codeBaseDir)
+	}
+	if err == nil {
+		err = exec.Command("sh", "-c", fmt.Sprintf("chmod a+x \"%s\"/*", codeBaseDir)).Run()
+	}
+
+	if !report.check("Code base", fmt.Sprintf("%v bytes extracted", len(codeBase)), err) {
+		report.skip("Adapters")
+		return report.finish()
+	}
+
+	// 4. adapters
+	report.check("Adapter 'executor'", "executable", checkAdapter(codeBaseDir, "executor"))
+
+	for _, adapter := range optionalAdapters {
+		if _, err := os.Stat(path.Join(codeBaseDir, adapter)); os.IsNotExist(err) {
+			report.check("Adapter '"+adapter+"'", "not provided", nil)
+		} else {
+			report.check("Adapter '"+adapter+"'", "executable", checkAdapter(codeBaseDir, adapter))
+		}
+	}
+
+	return report.finish()
+}
+
+// checkAdapter returns an error when the adapter is missing or cannot be executed
+func checkAdapter(codeBaseDir, adapter string) error {
+	info, err := os.Stat(path.Join(codeBaseDir, adapter))
+	if os.IsNotExist(err) {
+		return errors.New("There is no '" + adapter + "' in the code base.")
+	} else if err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return errors.New("'" + adapter + "' is not a regular file.")
+	}
+
+	if info.Mode().Perm()&0111 == 0 {
+		return errors.New("'" + adapter + "' is not executable.")
+	}
+
+	return nil
+}
+
+// credentialsError is returned when Experiment Managers reject the configured credentials,
+// Hint names the settings of the configured authentication method
+type credentialsError struct {
+	*HTTPStatusError
+	Hint string
+}
+
+func (e *credentialsError) Error() string {
+	return e.HTTPStatusError.Error() + ", " + e.Hint
+}
+
+// credentialsHint tells which settings of 'auth_method' should be checked when credentials are rejected
+func credentialsHint(config *SimulationManagerConfig) string {
+	switch config.AuthMethod {
+	case AuthMethodToken:
+		return "check auth_token"
+	case AuthMethodTokenFile:
+		return "check the token in auth_token_file " + config.AuthTokenFile
+	case AuthMethodProxyCertificate:
+		return "check the proxy certificate in proxy_certificate_path " + config.ProxyCertificatePath
+	}
+
+	return "check experiment_manager_user and experiment_manager_pass"
+}
+
+// doctorRequest executes a GET request against Experiment Managers and returns the response body
+func doctorRequest(servicePath string, endpoints *EndpointPool, config *SimulationManagerConfig, client *http.Client,
+	timeout time.Duration) ([]byte, error) {
+
+	em := &ScalarmClient{HttpClient: client, Endpoints: endpoints, Config: config, Timeout: timeout, Service: "Experiment manager"}
+	body, err := em.Read(context.Background(), ScalarmRequest{Method: "GET", ServiceMethod: servicePath})
+	if statusErr, ok := err.(*HTTPStatusError); ok && statusErr.Unauthorized() {
+		return nil, &credentialsError{statusErr, credentialsHint(config)}
+	}
+
+	return body, err
+}
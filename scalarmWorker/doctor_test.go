@@ -0,0 +1,150 @@
+package scalarmWorker
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/scalarm/scalarm_simulation_manager_go/fakeScalarm"
+)
+
+func TestDoctorShouldPassWhenEnvironmentIsReady(t *testing.T) {
+	// === GIVEN ===
+	codeBase := createCodeBase(t, map[string]string{"executor": "#!/bin/sh\n", "input_writer": "#!/bin/sh\n"})
+
+	server := httptest.NewServer(fakeScalarm.NewServer(fakeScalarm.Experiment{ID: "7", CodeBase: codeBase}))
+	defer server.Close()
+
+	config := getSimConfig()
+	config.ExperimentId = "7"
+	config.ExperimentManagerUrls = []string{server.URL}
+	config.StorageManagerUrls = []string{server.URL}
+	config.Timeout = 5
+
+	report := &bytes.Buffer{}
+
+	// === WHEN ===
+	passed := Doctor(config, report)
+
+	// === THEN ===
+	if !passed {
+		t.Errorf("Got: false - Expected true, report:\n%s", report)
+	}
+
+	for _, expected := range []string{
+		"[PASS] TLS: certificates loaded",
+		"[PASS] HTTP transport: created",
+		"[PASS] Experiment Managers: " + server.URL,
+		"[PASS] Credentials: access to experiment 7",
+		"[PASS] Adapter 'executor': executable",
+		"[PASS] Adapter 'input_writer': executable",
+		"[PASS] Adapter 'output_reader': not provided",
+		"All checks passed",
+	} {
+		if !strings.Contains(report.String(), expected) {
+			t.Errorf("Report does not contain '%v':\n%s", expected, report)
+		}
+	}
+}
+
+func TestDoctorShouldFailWhenCredentialsAreRejected(t *testing.T) {
+	// === GIVEN ===
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{ID: "7"})
+	fake.User, fake.Password = "user", "other"
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := getSimConfig()
+	config.ExperimentId = "7"
+	config.ExperimentManagerUrls = []string{server.URL}
+	config.StorageManagerUrls = []string{server.URL}
+	config.Timeout = 5
+
+	report := &bytes.Buffer{}
+
+	// === WHEN ===
+	passed := Doctor(config, report)
+
+	// === THEN ===
+	if passed {
+		t.Errorf("Got: true - Expected false, report:\n%s", report)
+	}
+
+	for _, expected := range []string{
+		"[FAIL] Credentials: Experiment manager response code: 401, check experiment_manager_user and experiment_manager_pass",
+		"[SKIP] Code base",
+		"1 check(s) failed",
+	} {
+		if !strings.Contains(report.String(), expected) {
+			t.Errorf("Report does not contain '%v':\n%s", expected, report)
+		}
+	}
+}
+
+func TestDoctorShouldHintSettingsOfConfiguredAuthMethodWhenCredentialsAreRejected(t *testing.T) {
+	// === GIVEN ===
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{ID: "7"})
+	fake.User, fake.Password = "user", "pass"
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := getSimConfig()
+	config.ExperimentId = "7"
+	config.ExperimentManagerUrls = []string{server.URL}
+	config.StorageManagerUrls = []string{server.URL}
+	config.AuthMethod = AuthMethodToken
+	config.AuthToken = "expired"
+	config.Timeout = 5
+
+	report := &bytes.Buffer{}
+
+	// === WHEN ===
+	passed := Doctor(config, report)
+
+	// === THEN ===
+	expected := "[FAIL] Credentials: Experiment manager response code: 401, check auth_token\n"
+	if passed || !strings.Contains(report.String(), expected) {
+		t.Errorf("Report does not contain '%v':\n%s", expected, report)
+	}
+}
+
+func TestDoctorShouldReportTransportErrorAndContinueChecks(t *testing.T) {
+	// === GIVEN ===
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{
+		ID:       "7",
+		CodeBase: createCodeBase(t, map[string]string{"executor": "#!/bin/sh\n"}),
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := getSimConfig()
+	config.ExperimentId = "7"
+	config.ExperimentManagerUrls = []string{server.URL}
+	config.StorageManagerUrls = []string{server.URL}
+	config.ProxyURL = "ftp://proxy.cluster"
+	config.Timeout = 5
+
+	report := &bytes.Buffer{}
+
+	// === WHEN ===
+	passed := Doctor(config, report)
+
+	// === THEN ===
+	if passed {
+		t.Errorf("Got: true - Expected false, report:\n%s", report)
+	}
+
+	for _, expected := range []string{
+		"[PASS] TLS: certificates loaded",
+		"[FAIL] HTTP transport: Incorrect proxy URL 'ftp://proxy.cluster': has unsupported scheme 'ftp'",
+		"[PASS] Experiment Managers: " + server.URL,
+		"[PASS] Credentials: access to experiment 7",
+		"[PASS] Adapter 'executor': executable",
+		"1 check(s) failed",
+	} {
+		if !strings.Contains(report.String(), expected) {
+			t.Errorf("Report does not contain '%v':\n%s", expected, report)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package scalarmWorker
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// default values of the circuit breaker of endpoints
+const (
+	defaultCircuitFailureThreshold = 3
+	defaultCircuitOpenDuration     = 60 * time.Second
+)
+
+// endpoint keeps health of a single service url
+type endpoint struct {
+	url                 string
+	consecutiveFailures int
+	latency             time.Duration
+	// circuit is open since this time, zero when the circuit is closed
+	openedAt time.Time
+}
+
+// EndpointPool tracks failures and latency of replicas of a Scalarm service. Replicas failing FailureThreshold times
+// in a row are not used for OpenDuration, after that a single request probes if they are back (half-open circuit).
+// The pool is safe for concurrent use and shared by all requests to the service.
+type EndpointPool struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+
+	mutex     sync.Mutex
+	endpoints []*endpoint
+	now       func() time.Time
+}
+
+// NewEndpointPool creates a pool of the given service urls with default circuit breaker settings
+func NewEndpointPool(urls []string) *EndpointPool {
+	pool := &EndpointPool{
+		FailureThreshold: defaultCircuitFailureThreshold,
+		OpenDuration:     defaultCircuitOpenDuration,
+		now:              time.Now,
+	}
+	pool.Update(urls)
+
+	return pool
+}
+
+// Update replaces service urls of the pool, health of urls which remain in the pool is kept
+func (pool *EndpointPool) Update(urls []string) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	endpoints := make([]*endpoint, 0, len(urls))
+	for _, url := range urls {
+		if existing := pool.find(url); existing != nil {
+			endpoints = append(endpoints, existing)
+		} else {
+			endpoints = append(endpoints, &endpoint{url: url})
+		}
+	}
+
+	pool.endpoints = endpoints
+}
+
+// URLs returns all service urls of the pool
+func (pool *EndpointPool) URLs() []string {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	urls := make([]string, len(pool.endpoints))
+	for i, e := range pool.endpoints {
+		urls[i] = e.url
+	}
+
+	return urls
+}
+
+// Len returns the number of service urls in the pool
+func (pool *EndpointPool) Len() int {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	return len(pool.endpoints)
+}
+
+// Candidates returns service urls to try in order of preference: healthy ones with fewer failures and lower latency
+// first, then ones whose circuit is half-open. When all circuits are open the one opened earliest is probed,
+// so a request is never rejected without trying.
+func (pool *EndpointPool) Candidates() []string {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	now := pool.now()
+	closed := []*endpoint{}
+	halfOpen := []*endpoint{}
+	var earliest *endpoint
+
+	// equally healthy endpoints are used in random order to spread load of many workers
+	for _, i := range rand.Perm(len(pool.endpoints)) {
+		e := pool.endpoints[i]
+
+		if e.openedAt.IsZero() {
+			closed = append(closed, e)
+		} else if now.Sub(e.openedAt) >= pool.OpenDuration {
+			halfOpen = append(halfOpen, e)
+		} else if earliest == nil || e.openedAt.Before(earliest.openedAt) {
+			earliest = e
+		}
+	}
+
+	sort.SliceStable(closed, func(i, j int) bool {
+		if closed[i].consecutiveFailures != closed[j].consecutiveFailures {
+			return closed[i].consecutiveFailures < closed[j].consecutiveFailures
+		}
+		return closed[i].latency < closed[j].latency
+	})
+
+	if len(closed) == 0 && len(halfOpen) == 0 && earliest != nil {
+		halfOpen = append(halfOpen, earliest)
+	}
+
+	candidates := make([]string, 0, len(closed)+len(halfOpen))
+	for _, e := range closed {
+		candidates = append(candidates, e.url)
+	}
+	for _, e := range halfOpen {
+		// only one request probes the endpoint, the next probe is allowed after another OpenDuration
+		e.openedAt = now
+		candidates = append(candidates, e.url)
+	}
+
+	return candidates
+}
+
+// Success records a successful request to the service url and closes its circuit
+func (pool *EndpointPool) Success(url string, latency time.Duration) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	e := pool.find(url)
+	if e == nil {
+		return
+	}
+
+	if !e.openedAt.IsZero() {
+		fmt.Printf("[SiM] %s is available again\n", url)
+	}
+
+	e.consecutiveFailures = 0
+	e.openedAt = time.Time{}
+
+	// exponentially weighted moving average
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = (7*e.latency + 3*latency) / 10
+	}
+}
+
+// Failure records a failed request to the service url and opens its circuit after too many failures in a row
+func (pool *EndpointPool) Failure(url string) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	e := pool.find(url)
+	if e == nil {
+		return
+	}
+
+	e.consecutiveFailures++
+	if e.consecutiveFailures >= pool.FailureThreshold {
+		if e.openedAt.IsZero() {
+			fmt.Printf("[SiM] %s failed %v times in a row, it will not be used for %v\n", url, e.consecutiveFailures, pool.OpenDuration)
+		}
+		e.openedAt = pool.now()
+	}
+}
+
+func (pool *EndpointPool) find(url string) *endpoint {
+	for _, e := range pool.endpoints {
+		if e.url == url {
+			return e
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package scalarmWorker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func getEndpointPoolMock(urls []string, now *time.Time) *EndpointPool {
+	pool := NewEndpointPool(urls)
+	pool.now = func() time.Time { return *now }
+	return pool
+}
+
+func TestEndpointPoolShouldOpenCircuitOfFailingEndpointAndProbeItWhenHalfOpen(t *testing.T) {
+	// === GIVEN ===
+	now := time.Now()
+	pool := getEndpointPoolMock([]string{"siteA.com", "siteB.com"}, &now)
+
+	// === WHEN ===
+	for i := 0; i < defaultCircuitFailureThreshold; i++ {
+		pool.Failure("siteA.com")
+	}
+
+	// === THEN ===
+	if candidates := pool.Candidates(); !reflect.DeepEqual(candidates, []string{"siteB.com"}) {
+		t.Errorf("Got: '%v' - Expected only siteB.com while the circuit is open", candidates)
+	}
+
+	now = now.Add(defaultCircuitOpenDuration)
+	if candidates := pool.Candidates(); !reflect.DeepEqual(candidates, []string{"siteB.com", "siteA.com"}) {
+		t.Errorf("Got: '%v' - Expected siteA.com to be probed after siteB.com", candidates)
+	}
+
+	if candidates := pool.Candidates(); !reflect.DeepEqual(candidates, []string{"siteB.com"}) {
+		t.Errorf("Got: '%v' - Expected siteA.com to be probed only once", candidates)
+	}
+
+	pool.Success("siteA.com", time.Millisecond)
+	if candidates := pool.Candidates(); len(candidates) != 2 {
+		t.Errorf("Got: '%v' - Expected both endpoints after successful probe", candidates)
+	}
+}
+
+func TestEndpointPoolShouldPreferHealthyAndFastEndpoints(t *testing.T) {
+	// === GIVEN ===
+	now := time.Now()
+	pool := getEndpointPoolMock([]string{"slow.com", "failing.com", "fast.com"}, &now)
+
+	// === WHEN ===
+	pool.Success("slow.com", 2*time.Second)
+	pool.Success("fast.com", 100*time.Millisecond)
+	pool.Success("failing.com", time.Millisecond)
+	pool.Failure("failing.com")
+
+	// === THEN ===
+	expected := []string{"fast.com", "slow.com", "failing.com"}
+	if candidates := pool.Candidates(); !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("Got: '%v' - Expected '%v'", candidates, expected)
+	}
+}
+
+func TestEndpointPoolShouldProbeEarliestOpenedEndpointWhenAllCircuitsAreOpen(t *testing.T) {
+	// === GIVEN ===
+	now := time.Now()
+	pool := getEndpointPoolMock([]string{"siteA.com", "siteB.com"}, &now)
+
+	for i := 0; i < defaultCircuitFailureThreshold; i++ {
+		pool.Failure("siteA.com")
+	}
+	now = now.Add(time.Second)
+	for i := 0; i < defaultCircuitFailureThreshold; i++ {
+		pool.Failure("siteB.com")
+	}
+
+	// === WHEN ===
+	candidates := pool.Candidates()
+
+	// === THEN ===
+	if !reflect.DeepEqual(candidates, []string{"siteA.com"}) {
+		t.Errorf("Got: '%v' - Expected siteA.com", candidates)
+	}
+}
+
+func TestEndpointPoolUpdateShouldKeepHealthOfRemainingEndpoints(t *testing.T) {
+	// === GIVEN ===
+	now := time.Now()
+	pool := getEndpointPoolMock([]string{"siteA.com", "siteB.com"}, &now)
+	for i := 0; i < defaultCircuitFailureThreshold; i++ {
+		pool.Failure("siteA.com")
+	}
+
+	// === WHEN ===
+	pool.Update([]string{"siteA.com", "siteC.com"})
+
+	// === THEN ===
+	if candidates := pool.Candidates(); !reflect.DeepEqual(candidates, []string{"siteC.com"}) {
+		t.Errorf("Got: '%v' - Expected only siteC.com", candidates)
+	}
+}
+
+func TestScalarmClientShouldStopUsingDeadReplica(t *testing.T) {
+	// === GIVEN ===
+	deadReplicaRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "dead.com" {
+			deadReplicaRequests++
+			w.WriteHeader(503)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	config := getSimConfig()
+	config.RetryMaxAttempts = 1
+	pool := NewEndpointPool([]string{"dead.com", "alive.com"})
+	client := &ScalarmClient{HttpClient: getHttpClientMock(server.URL), Endpoints: pool, Config: config, Timeout: time.Second}
+
+	// === WHEN ===
+	for i := 0; i < 10; i++ {
+		resp, err := client.Do(context.Background(), ScalarmRequest{Method: "GET", ServiceMethod: "experiments"})
+
+		// === THEN ===
+		if err != nil || resp.StatusCode != 200 {
+			t.Fatalf("Got: '%v', '%v' - Expected response code 200", resp, err)
+		}
+		resp.Body.Close()
+	}
+
+	if deadReplicaRequests > defaultCircuitFailureThreshold {
+		t.Errorf("Got: %v requests to the dead replica - Expected at most %v", deadReplicaRequests, defaultCircuitFailureThreshold)
+	}
+}
@@ -1,24 +1,29 @@
 package scalarmWorker
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"strconv"
-	"strings"
 	"time"
 )
 
+// ProgressReporter receives information about a simulation run while it is being executed,
+// it is implemented by ExperimentManager and by the local reporter used in the offline mode
+type ProgressReporter interface {
+	ReportHostInfoContext(ctx context.Context, simulationIndex int, hostInfo *HostInfo) error
+	ReportPerformanceStatsContext(ctx context.Context, simulationIndex int, perfStats *PerformanceStats) error
+	PostProgressInfoContext(ctx context.Context, simulationIndex int, results url.Values) error
+}
+
 type ExperimentManager struct {
 	HttpClient           *http.Client
-	BaseUrls             []string
+	Endpoints            *EndpointPool
 	CommunicationTimeout time.Duration
 	Config               *SimulationManagerConfig
 	Username             string
@@ -26,197 +31,198 @@ type ExperimentManager struct {
 	ExperimentId         string
 }
 
-func (em *ExperimentManager) GetNextSimulationRunConfig() (map[string]interface{}, error) {
-	nextSimulationRunConfig := map[string]interface{}{}
-
-	path := "experiments/" + em.ExperimentId + "/next_simulation"
-	reqInfo := RequestInfo{"GET", nil, "", path}
+// client returns the client executing requests against Experiment Managers
+func (em *ExperimentManager) client() *ScalarmClient {
+	return &ScalarmClient{
+		HttpClient: em.HttpClient,
+		Endpoints:  em.Endpoints,
+		Config:     em.Config,
+		Timeout:    em.CommunicationTimeout,
+		Service:    "Experiment manager",
+	}
+}
 
-	resp, err := ExecuteScalarmRequest(reqInfo, em.BaseUrls, em.Config, em.HttpClient, em.CommunicationTimeout)
+func (em *ExperimentManager) simulationPath(simulationIndex int, method string) string {
+	return "experiments/" + em.ExperimentId + "/simulations/" + strconv.Itoa(simulationIndex) + "/" + method
+}
 
+// GetRandomExperimentIDContext returns id of a random experiment available for the user, it is empty when there is none
+func (em *ExperimentManager) GetRandomExperimentIDContext(ctx context.Context) (string, error) {
+	body, err := em.client().Read(ctx, ScalarmRequest{Method: "GET", ServiceMethod: "experiments/random_experiment"})
 	if err != nil {
-		return nil, err
-	} else {
-		if resp.StatusCode == 200 {
-			defer resp.Body.Close()
-
-			body, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				return nil, err
-			}
+		return "", err
+	}
 
-			if err := json.Unmarshal(body, &nextSimulationRunConfig); err != nil {
-				return nil, errors.New("Returned response body is not JSON.")
-			}
+	return string(body), nil
+}
 
-			return nextSimulationRunConfig, nil
+// SimulationRunConfig is the answer of Experiment Manager to experiments/:id/next_simulation
+type SimulationRunConfig struct {
+	Status               string                 `json:"status"`
+	Reason               string                 `json:"reason"`
+	SimulationID         int                    `json:"simulation_id"`
+	InputParameters      map[string]interface{} `json:"input_parameters"`
+	ExecutionConstraints ExecutionConstraints   `json:"execution_constraints"`
+	// time to wait when the status is 'wait'
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
 
-		} else if resp.StatusCode == 500 {
-			return nil, errors.New("Experiment manager response code: 500")
-		} else {
-			return nil, errors.New("Experiment manager response code: " + strconv.Itoa(resp.StatusCode))
-		}
-	}
+// ExecutionConstraints limit how a simulation run is executed
+type ExecutionConstraints struct {
+	TimeConstraintInSec float64 `json:"time_constraint_in_sec"`
 }
 
-func (em *ExperimentManager) MarkSimulationRunAsComplete(simulationIndex int, runResult url.Values) (map[string]interface{}, error) {
-	emResponse := map[string]interface{}{}
+// validate returns ScalarmError when fields required by the status are missing or invalid
+func (config *SimulationRunConfig) validate() error {
+	problem := ""
+	switch {
+	case config.Status == "":
+		problem = "status is missing"
+	case config.Status == "ok" && config.SimulationID <= 0:
+		problem = "simulation_id is missing or not positive"
+	case config.Status == "ok" && config.InputParameters == nil:
+		problem = "input_parameters are missing"
+	case config.Status == "wait" && config.DurationInSeconds < 0:
+		problem = "duration_in_seconds is negative"
+	}
 
-	path := "experiments/" + em.ExperimentId + "/simulations/" + strconv.Itoa(simulationIndex) + "/mark_as_complete"
-	reqInfo := RequestInfo{"POST", strings.NewReader(runResult.Encode()), "application/x-www-form-urlencoded", path}
+	if problem != "" {
+		return &ScalarmError{Status: "error", Reason: "Incorrect next simulation run: " + problem}
+	}
+	return nil
+}
 
-	resp, err := ExecuteScalarmRequest(reqInfo, em.BaseUrls, em.Config, em.HttpClient, em.CommunicationTimeout)
+func (em *ExperimentManager) GetNextSimulationRunConfig() (*SimulationRunConfig, error) {
+	return em.GetNextSimulationRunConfigContext(context.Background())
+}
 
+// GetNextSimulationRunConfigContext works as GetNextSimulationRunConfig, the request is aborted when ctx is done
+func (em *ExperimentManager) GetNextSimulationRunConfigContext(ctx context.Context) (*SimulationRunConfig, error) {
+	request := ScalarmRequest{Method: "GET", ServiceMethod: "experiments/" + em.ExperimentId + "/next_simulation"}
+	body, err := em.client().Read(ctx, request)
 	if err != nil {
 		return nil, err
-	} else {
-		if resp.StatusCode == 200 {
-			defer resp.Body.Close()
-
-			body, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				return nil, err
-			}
+	}
 
-			if err := json.Unmarshal(body, &emResponse); err != nil {
-				fmt.Printf("Receiving: %v\n", body)
-				return nil, errors.New("Returned response body is not JSON.")
-			}
+	config := &SimulationRunConfig{}
+	if err := json.Unmarshal(body, config); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			fmt.Printf("[SiM] Receiving: %s\n", body)
+			return nil, errors.New("Returned response body is not JSON.")
+		}
+		return nil, &ScalarmError{Status: "error", Reason: "Incorrect next simulation run: " + err.Error()}
+	}
 
-			if statusVal, ok := emResponse["status"]; ok {
-				if statusVal.(string) != "ok" && statusVal.(string) != "preconditioned_failed" {
-					if reasonVal, ok := emResponse["reason"]; ok {
-						return nil, errors.New(reasonVal.(string))
-					}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
 
-					return nil, errors.New("Something went wrong but without any details")
-				}
-			}
+	return config, nil
+}
 
-			return emResponse, nil
+func (em *ExperimentManager) MarkSimulationRunAsComplete(simulationIndex int, runResult url.Values) (map[string]interface{}, error) {
+	return em.MarkSimulationRunAsCompleteContext(context.Background(), simulationIndex, runResult)
+}
 
-		} else if resp.StatusCode == 500 {
+// MarkSimulationRunAsCompleteContext works as MarkSimulationRunAsComplete, the request is aborted when ctx is done
+func (em *ExperimentManager) MarkSimulationRunAsCompleteContext(ctx context.Context, simulationIndex int, runResult url.Values) (map[string]interface{}, error) {
+	emResponse := map[string]interface{}{}
 
-			return nil, errors.New("Experiment manager response code: 500")
+	request := NewFormRequest("POST", em.simulationPath(simulationIndex, "mark_as_complete"), runResult)
+	if err := em.client().ReadJSON(ctx, request, &emResponse); err != nil {
+		return nil, err
+	}
 
-		} else {
+	if err := statusOf(emResponse).Err("ok", "preconditioned_failed"); err != nil {
+		return nil, err
+	}
 
-			return nil, errors.New("Experiment manager response code: " + strconv.Itoa(resp.StatusCode))
+	return emResponse, nil
+}
 
-		}
-	}
+// ResetSimulationRun gives a fetched simulation run back to Experiment Manager which sends it to another worker
+func (em *ExperimentManager) ResetSimulationRun(simulationIndex int) error {
+	return em.ResetSimulationRunContext(context.Background(), simulationIndex)
 }
 
-func (em *ExperimentManager) DownloadExperimentCodeBase(codeBaseDir string) error {
-	var responseBody []byte
+// ResetSimulationRunContext works as ResetSimulationRun, the request is aborted when ctx is done
+func (em *ExperimentManager) ResetSimulationRunContext(ctx context.Context, simulationIndex int) error {
+	var emResponse StatusResponse
 
-	w, err := os.Create(path.Join(codeBaseDir, "code_base.zip"))
-	if err != nil {
+	request := ScalarmRequest{Method: "DELETE", ServiceMethod: "experiments/" + em.ExperimentId + "/simulations/" + strconv.Itoa(simulationIndex)}
+	if err := em.client().ReadJSON(ctx, request, &emResponse); err != nil {
 		return err
 	}
-	defer w.Close()
 
-	codeBaseURL := "experiments/" + em.ExperimentId + "/code_base"
-	codeBaseInfo := RequestInfo{"GET", nil, "", codeBaseURL}
+	return emResponse.Err()
+}
 
-	resp, err := ExecuteScalarmRequest(codeBaseInfo, em.BaseUrls, em.Config, em.HttpClient, em.CommunicationTimeout)
-	if err != nil {
-		return err
-	}
+func (em *ExperimentManager) DownloadExperimentCodeBase(codeBaseDir string) error {
+	return em.DownloadExperimentCodeBaseContext(context.Background(), codeBaseDir)
+}
 
-	responseBody, err = ioutil.ReadAll(resp.Body)
+// DownloadExperimentCodeBaseContext works as DownloadExperimentCodeBase, the request is aborted when ctx is done
+func (em *ExperimentManager) DownloadExperimentCodeBaseContext(ctx context.Context, codeBaseDir string) error {
+	w, err := os.Create(path.Join(codeBaseDir, "code_base.zip"))
 	if err != nil {
 		return err
 	}
+	defer w.Close()
 
-	if _, err = io.Copy(w, bytes.NewReader(responseBody)); err != nil {
-		return err
-	}
+	request := ScalarmRequest{Method: "GET", ServiceMethod: "experiments/" + em.ExperimentId + "/code_base"}
+	_, err = em.client().Download(ctx, request, w)
 
-	return nil
+	return err
 }
 
 func (em *ExperimentManager) PostProgressInfo(simulationIndex int, results url.Values) error {
-	emResponse := map[string]interface{}{}
-
-	progressInfoPath := "experiments/" + em.ExperimentId + "/simulations/" + strconv.Itoa(simulationIndex) + "/progress_info"
-	reqInfo := RequestInfo{"POST", strings.NewReader(results.Encode()), "application/x-www-form-urlencoded", progressInfoPath}
-
-	resp, err := ExecuteScalarmRequest(reqInfo, em.BaseUrls, em.Config, em.HttpClient, em.CommunicationTimeout)
-
-	if err != nil {
-		return err
-	}
-
-	if resp.StatusCode != 200 {
-		return errors.New("Experiment manager response code: " + strconv.Itoa(resp.StatusCode))
-	}
+	return em.PostProgressInfoContext(context.Background(), simulationIndex, results)
+}
 
-	defer resp.Body.Close()
+// PostProgressInfoContext works as PostProgressInfo, the request is aborted when ctx is done
+func (em *ExperimentManager) PostProgressInfoContext(ctx context.Context, simulationIndex int, results url.Values) error {
+	var emResponse StatusResponse
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	request := NewFormRequest("POST", em.simulationPath(simulationIndex, "progress_info"), results)
+	if err := em.client().ReadJSON(ctx, request, &emResponse); err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal(body, &emResponse); err != nil {
-		return errors.New("Returned response body is not JSON.")
-	}
-
-	if statusVal, ok := emResponse["status"]; ok {
-		if statusVal.(string) != "ok" {
-			if reasonVal, ok := emResponse["reason"]; ok {
-				return errors.New(reasonVal.(string))
-			}
-
-			return errors.New("Something went wrong but without any details")
-		}
-	}
-
-	return nil
+	return emResponse.Err()
 }
 
 // ReportHostInfo sends information about the host where computations are executed
 func (em *ExperimentManager) ReportHostInfo(simulationIndex int, hostInfo *HostInfo) error {
+	return em.ReportHostInfoContext(context.Background(), simulationIndex, hostInfo)
+}
+
+// ReportHostInfoContext works as ReportHostInfo, the request is aborted when ctx is done
+func (em *ExperimentManager) ReportHostInfoContext(ctx context.Context, simulationIndex int, hostInfo *HostInfo) error {
 	jsonStr, _ := json.Marshal(hostInfo)
 	requestData := url.Values{}
 	requestData.Set("host_info", string(jsonStr))
 
-	url := "experiments/" + em.ExperimentId + "/simulations/" + strconv.Itoa(simulationIndex) + "/host_info"
-	reqInfo := RequestInfo{"POST", strings.NewReader(requestData.Encode()), "application/x-www-form-urlencoded", url}
-
-	resp, err := ExecuteScalarmRequest(reqInfo, em.BaseUrls, em.Config, em.HttpClient, em.CommunicationTimeout)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return errors.New("Experiment manager response code: " + strconv.Itoa(resp.StatusCode))
-	}
-
-	return nil
+	_, err := em.client().Read(ctx, NewFormRequest("POST", em.simulationPath(simulationIndex, "host_info"), requestData))
+	return err
 }
 
 func (em *ExperimentManager) ReportPerformanceStats(simulationIndex int, perfStats *PerformanceStats) error {
+	return em.ReportPerformanceStatsContext(context.Background(), simulationIndex, perfStats)
+}
+
+// ReportPerformanceStatsContext works as ReportPerformanceStats, the request is aborted when ctx is done
+func (em *ExperimentManager) ReportPerformanceStatsContext(ctx context.Context, simulationIndex int, perfStats *PerformanceStats) error {
 	jsonStr, _ := json.Marshal(perfStats)
 	requestData := url.Values{}
 	requestData.Set("stats", string(jsonStr))
 
-	url := "experiments/" + em.ExperimentId + "/simulations/" + strconv.Itoa(simulationIndex) + "/performance_stats"
-	reqInfo := RequestInfo{"POST", strings.NewReader(requestData.Encode()), "application/x-www-form-urlencoded", url}
-
-	resp, err := ExecuteScalarmRequest(reqInfo, em.BaseUrls, em.Config, em.HttpClient, em.CommunicationTimeout)
-	defer resp.Body.Close()
-
-	if err != nil {
-		return err
-	}
-
-	if resp.StatusCode != 200 {
-		return errors.New("Experiment manager response code: " + strconv.Itoa(resp.StatusCode))
-	}
+	_, err := em.client().Read(ctx, NewFormRequest("POST", em.simulationPath(simulationIndex, "performance_stats"), requestData))
+	return err
+}
 
-	return nil
+// statusOf returns the status and the reason given in a decoded JSON answer of a Scalarm service
+func statusOf(response map[string]interface{}) StatusResponse {
+	status, _ := response["status"].(string)
+	reason, _ := response["reason"].(string)
+	return StatusResponse{Status: status, Reason: reason}
 }
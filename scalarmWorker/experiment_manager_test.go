@@ -16,7 +16,7 @@ import (
 func setupExperimentManager(config *SimulationManagerConfig, client *http.Client) ExperimentManager {
 	return ExperimentManager{
 		HttpClient:           client,
-		BaseUrls:             []string{"system.scalarm.com"},
+		Endpoints:            NewEndpointPool([]string{"system.scalarm.com"}),
 		CommunicationTimeout: 5 * time.Second,
 		Config:               config,
 		ExperimentId:         "568e5bece138232e76000002"}
@@ -54,7 +54,8 @@ func TestExperimentManagerShouldAskForJsonFormatWhenRequestingNextSimulation(t *
 		return
 	}
 
-	if nextSimulationRunConfig["status"].(string) != "ok" {
+	if nextSimulationRunConfig.Status != "ok" || nextSimulationRunConfig.SimulationID != 1 ||
+		nextSimulationRunConfig.TimeConstraint() != 3300*time.Second {
 		t.Errorf("Returned next simulation run config is what we expected to be. Actual: %v, Expected: %v",
 			nextSimulationRunConfig, "ok")
 	}
@@ -205,3 +206,29 @@ func TestExperimentManagerShouldHandleScalarmErrorsWithoutReasonsWhenSendingMark
 		return
 	}
 }
+
+func TestExperimentManagerShouldReturnScalarmErrorForMalformedNextSimulationRun(t *testing.T) {
+	responses := []string{
+		`{"status":"ok","input_parameters":{"parameter1":1}}`,
+		`{"status":"ok","simulation_id":"first","input_parameters":{}}`,
+		`{"simulation_id":1,"input_parameters":{}}`,
+	}
+
+	for _, response := range responses {
+		// === GIVEN ===
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, response)
+		}))
+
+		em := setupExperimentManager(getSimConfig(), getHttpClientMock(server.URL))
+
+		// === WHEN ===
+		config, err := em.GetNextSimulationRunConfig()
+		server.Close()
+
+		// === THEN ===
+		if _, ok := err.(*ScalarmError); !ok || config != nil {
+			t.Errorf("Got: '%v', '%v' - Expected ScalarmError for '%v'", config, err, response)
+		}
+	}
+}
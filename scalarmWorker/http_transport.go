@@ -0,0 +1,105 @@
+package scalarmWorker
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// value of 'proxy_url' which disables proxies, also the ones from environment variables
+const proxyNone = "none"
+
+// default values of the transport settings used when they are not set in the config
+const (
+	defaultConnectTimeout            = 30 * time.Second
+	defaultTLSHandshakeTimeout       = 10 * time.Second
+	defaultKeepAlive                 = 30 * time.Second
+	defaultMaxIdleConnections        = 100
+	defaultMaxIdleConnectionsPerHost = 2
+	defaultIdleConnectionTimeout     = 90 * time.Second
+)
+
+// NewHTTPTransport creates the transport of connections to Scalarm services from the config,
+// missing values are replaced with defaults. Without 'proxy_url' proxies are taken from
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+func NewHTTPTransport(config *SimulationManagerConfig, tlsConfig *tls.Config) (*http.Transport, error) {
+	proxy, err := proxyFunc(config.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   secondsOrDefault(config.ConnectTimeout, defaultConnectTimeout),
+		KeepAlive: secondsOrDefault(config.KeepAlive, defaultKeepAlive),
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   secondsOrDefault(config.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: time.Duration(config.ResponseHeaderTimeout) * time.Second,
+		MaxIdleConns:          config.MaxIdleConnections,
+		MaxIdleConnsPerHost:   config.MaxIdleConnectionsPerHost,
+		IdleConnTimeout:       secondsOrDefault(config.IdleConnectionTimeout, defaultIdleConnectionTimeout),
+	}
+
+	if transport.MaxIdleConns <= 0 {
+		transport.MaxIdleConns = defaultMaxIdleConnections
+	}
+
+	if transport.MaxIdleConnsPerHost <= 0 {
+		transport.MaxIdleConnsPerHost = defaultMaxIdleConnectionsPerHost
+	}
+
+	return transport, nil
+}
+
+// proxyFunc returns the proxy used by the transport: from the environment when proxyURL is empty,
+// no proxy when it is 'none', the given one otherwise
+func proxyFunc(proxyURL string) (func(*http.Request) (*url.URL, error), error) {
+	switch proxyURL {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case proxyNone:
+		return nil, nil
+	}
+
+	parsedURL, err := parseProxyURL(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("Incorrect proxy URL '%s': %v", proxyURL, err)
+	}
+
+	return http.ProxyURL(parsedURL), nil
+}
+
+// parseProxyURL accepts HTTP(S) and SOCKS5 proxies, e.g. 'http://proxy.cluster:3128'
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	parsedURL, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("is not a valid URL: %v", err)
+	}
+
+	switch parsedURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("has unsupported scheme '%s'", parsedURL.Scheme)
+	}
+
+	if parsedURL.Host == "" {
+		return nil, fmt.Errorf("has no host")
+	}
+
+	return parsedURL, nil
+}
+
+func secondsOrDefault(seconds int, defaultValue time.Duration) time.Duration {
+	if seconds <= 0 {
+		return defaultValue
+	}
+
+	return time.Duration(seconds) * time.Second
+}
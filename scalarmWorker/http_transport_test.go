@@ -0,0 +1,84 @@
+package scalarmWorker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPTransportShouldSendRequestsThroughConfiguredProxy(t *testing.T) {
+	// === GIVEN ===
+	proxiedHost := ""
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedHost = r.URL.Host
+	}))
+	defer proxy.Close()
+
+	config := &SimulationManagerConfig{ProxyURL: proxy.URL}
+
+	// === WHEN ===
+	transport, err := NewHTTPTransport(config, nil)
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	client := &http.Client{Transport: transport}
+	resp, err := client.Get("http://em.scalarm.invalid/experiments")
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected response from the proxy", err)
+	}
+	resp.Body.Close()
+
+	if proxiedHost != "em.scalarm.invalid" {
+		t.Errorf("Got: '%v' - Expected 'em.scalarm.invalid'", proxiedHost)
+	}
+}
+
+func TestNewHTTPTransportShouldUseConfiguredSettingsAndDefaults(t *testing.T) {
+	// === GIVEN ===
+	config := &SimulationManagerConfig{
+		ProxyURL:              "none",
+		TLSHandshakeTimeout:   5,
+		ResponseHeaderTimeout: 20,
+		MaxIdleConnections:    8,
+	}
+
+	// === WHEN ===
+	transport, err := NewHTTPTransport(config, nil)
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	if transport.Proxy != nil {
+		t.Errorf("Got: proxy function - Expected no proxy")
+	}
+
+	if transport.TLSHandshakeTimeout != 5*time.Second || transport.ResponseHeaderTimeout != 20*time.Second {
+		t.Errorf("Got: %v, %v - Expected 5s, 20s", transport.TLSHandshakeTimeout, transport.ResponseHeaderTimeout)
+	}
+
+	if transport.MaxIdleConns != 8 || transport.MaxIdleConnsPerHost != defaultMaxIdleConnectionsPerHost ||
+		transport.IdleConnTimeout != defaultIdleConnectionTimeout {
+		t.Errorf("Got: %v, %v, %v - Expected 8, %v, %v", transport.MaxIdleConns, transport.MaxIdleConnsPerHost,
+			transport.IdleConnTimeout, defaultMaxIdleConnectionsPerHost, defaultIdleConnectionTimeout)
+	}
+}
+
+func TestNewHTTPTransportShouldRejectProxyWithUnsupportedScheme(t *testing.T) {
+	// === GIVEN ===
+	config := &SimulationManagerConfig{ProxyURL: "ftp://proxy.cluster:21"}
+
+	// === WHEN ===
+	transport, err := NewHTTPTransport(config, nil)
+
+	// === THEN ===
+	expectedMsg := "Incorrect proxy URL 'ftp://proxy.cluster:21': has unsupported scheme 'ftp'"
+	if transport != nil || err == nil || err.Error() != expectedMsg {
+		t.Errorf("Got: '%v', '%v' - Expected '%v'", transport, err, expectedMsg)
+	}
+}
@@ -1,76 +1,165 @@
 package scalarmWorker
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"strconv"
 	"time"
 )
 
 type InformationService struct {
 	HttpClient           *http.Client
 	BaseUrl              string
+	Endpoints            *EndpointPool
 	CommunicationTimeout time.Duration
 	Config               *SimulationManagerConfig
+	// answers are cached in this file when set and used when Information Service is unavailable
+	CachePath   string
+	CacheMaxAge time.Duration
 }
 
-func (is *InformationService) GetExperimentManagers() ([]string, error) {
-	iSReqInfo := RequestInfo{"GET", nil, "application/json", "experiment_managers"}
+// endpoints returns the pool shared by requests to Information Service, it is created from BaseUrl when not set
+func (is *InformationService) endpoints() *EndpointPool {
+	if is.Endpoints == nil {
+		is.Endpoints = NewEndpointPool([]string{is.BaseUrl})
+	}
+	return is.Endpoints
+}
 
-	resp, err := ExecuteScalarmRequest(iSReqInfo, []string{is.BaseUrl}, is.Config, is.HttpClient, is.CommunicationTimeout)
+// GetExperimentManagers returns addresses of Experiment Managers given in the config or asks Information Service for them
+func (is *InformationService) GetExperimentManagers() ([]string, error) {
+	return is.GetExperimentManagersContext(context.Background())
+}
 
-	if err != nil {
-		return nil, err
-	} else {
-		return ParseInformationServiceResponse(resp)
+// GetExperimentManagersContext works as GetExperimentManagers, the request is aborted when ctx is done
+func (is *InformationService) GetExperimentManagersContext(ctx context.Context) ([]string, error) {
+	if len(is.Config.ExperimentManagerUrls) > 0 {
+		return is.Config.ExperimentManagerUrls, nil
 	}
+
+	return is.getServiceList(ctx, "experiment_managers")
 }
 
+// GetStorageManagers returns addresses of Storage Managers given in the config or asks Information Service for them
 func (is *InformationService) GetStorageManagers() ([]string, error) {
-	iSReqInfo := RequestInfo{"GET", nil, "application/json", "storage_managers"}
+	return is.GetStorageManagersContext(context.Background())
+}
+
+// GetStorageManagersContext works as GetStorageManagers, the request is aborted when ctx is done
+func (is *InformationService) GetStorageManagersContext(ctx context.Context) ([]string, error) {
+	if len(is.Config.StorageManagerUrls) > 0 {
+		return is.Config.StorageManagerUrls, nil
+	}
+
+	return is.getServiceList(ctx, "storage_managers")
+}
+
+// getServiceList asks Information Service for addresses of a service, the answer is cached
+// and the cached one is used when Information Service is unavailable
+func (is *InformationService) getServiceList(ctx context.Context, serviceMethod string) ([]string, error) {
+	client := &ScalarmClient{
+		HttpClient: is.HttpClient,
+		Endpoints:  is.endpoints(),
+		Config:     is.Config,
+		Timeout:    is.CommunicationTimeout,
+		Service:    "Information service",
+	}
+
+	body, err := client.Read(ctx, ScalarmRequest{Method: "GET", ServiceMethod: serviceMethod})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var serviceUrls []string
+	if err == nil {
+		serviceUrls, err = parseServiceList(body)
+	}
+
+	if is.CachePath == "" {
+		return serviceUrls, err
+	}
 
-	resp, err := ExecuteScalarmRequest(iSReqInfo, []string{is.BaseUrl}, is.Config, is.HttpClient, is.CommunicationTimeout)
+	if err == nil {
+		if cacheErr := saveCachedServiceList(is.CachePath, serviceMethod, serviceUrls, time.Now()); cacheErr != nil {
+			fmt.Printf("[SiM] Could not cache Information Service answer: %v\n", cacheErr)
+		}
+		return serviceUrls, nil
+	}
 
-	if err != nil {
+	cached, updatedAt, cacheErr := loadCachedServiceList(is.CachePath, serviceMethod)
+	if cacheErr != nil {
+		return nil, err
+	}
+
+	if age := time.Since(updatedAt); is.CacheMaxAge > 0 && age > is.CacheMaxAge {
+		fmt.Printf("[SiM] Cached '%s' from %v are too old to be used\n", serviceMethod, updatedAt.Format(time.RFC3339))
 		return nil, err
-	} else {
-		return ParseInformationServiceResponse(resp)
 	}
+
+	fmt.Printf("[SiM] WARNING: Information Service is unavailable (%v), using cached '%s' from %v: %v\n",
+		err, serviceMethod, updatedAt.Format(time.RFC3339), cached)
+	return cached, nil
 }
 
-func ParseInformationServiceResponse(resp *http.Response) ([]string, error) {
-	var experimentManagers []string
+// RefreshManagers re-resolves Experiment and Storage Managers at the given interval and swaps them into the pools,
+// the last known lists are kept when Information Service is unavailable; the returned function stops refreshing
+func (is *InformationService) RefreshManagers(interval time.Duration, experimentManagers, storageManagers *EndpointPool) func() {
+	return is.RefreshManagersContext(context.Background(), interval, experimentManagers, storageManagers)
+}
 
-	if resp.StatusCode == 200 {
-		defer resp.Body.Close()
+// RefreshManagersContext works as RefreshManagers, refreshing also stops when ctx is done;
+// stopping aborts a refresh in progress
+func (is *InformationService) RefreshManagersContext(ctx context.Context, interval time.Duration,
+	experimentManagers, storageManagers *EndpointPool) func() {
 
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
+	ctx, cancel := context.WithCancel(ctx)
 
-		fmt.Printf("[SiM] Response body: %s.\n", body)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
 
-		if err := json.Unmarshal(body, &experimentManagers); err != nil {
-			return nil, errors.New("Returned response body is not JSON.")
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				is.refreshManagers(ctx, experimentManagers, storageManagers)
+			}
 		}
+	}()
 
-		if len(experimentManagers) == 0 {
-			return nil, errors.New("There is no Experiment Manager registered in Information Service. Please contact Scalarm administrators.")
-		}
+	return cancel
+}
 
-		return experimentManagers, nil
+func (is *InformationService) refreshManagers(ctx context.Context, experimentManagers, storageManagers *EndpointPool) {
+	if urls, err := is.GetExperimentManagersContext(ctx); err != nil {
+		fmt.Printf("[SiM] Could not refresh Experiment Managers, using the last known ones: %v\n", err)
+	} else {
+		experimentManagers.Update(urls)
+	}
 
-	} else if resp.StatusCode == 500 {
+	if urls, err := is.GetStorageManagersContext(ctx); err != nil {
+		fmt.Printf("[SiM] Could not refresh Storage Managers, using the last known ones: %v\n", err)
+	} else {
+		storageManagers.Update(urls)
+	}
+}
 
-		return nil, errors.New("Information service response code: 500")
+// parseServiceList parses a JSON list of service addresses returned by Information Service
+func parseServiceList(body []byte) ([]string, error) {
+	var serviceUrls []string
 
-	} else {
+	fmt.Printf("[SiM] Response body: %s.\n", body)
 
-		return nil, errors.New("Information service response code: " + strconv.Itoa(resp.StatusCode))
+	if err := json.Unmarshal(body, &serviceUrls); err != nil {
+		return nil, errors.New("Returned response body is not JSON.")
+	}
 
+	if len(serviceUrls) == 0 {
+		return nil, errors.New("There is no Experiment Manager registered in Information Service. Please contact Scalarm administrators.")
 	}
+
+	return serviceUrls, nil
 }
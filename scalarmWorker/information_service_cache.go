@@ -0,0 +1,74 @@
+package scalarmWorker
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// cachedServiceList is the last successful answer of Information Service about a service
+type cachedServiceList struct {
+	Urls      []string  `json:"urls"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// guards the cache file shared by lookups of different services
+var cacheMutex sync.Mutex
+
+func readServiceListsCache(cachePath string) (map[string]cachedServiceList, error) {
+	cache := map[string]cachedServiceList{}
+
+	content, err := ioutil.ReadFile(cachePath)
+	if err != nil {
+		return cache, err
+	}
+
+	if err := json.Unmarshal(content, &cache); err != nil {
+		return map[string]cachedServiceList{}, err
+	}
+
+	return cache, nil
+}
+
+// saveCachedServiceList stores addresses of a service in the cache file, the file is replaced atomically
+func saveCachedServiceList(cachePath, serviceMethod string, urls []string, updatedAt time.Time) error {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	// a missing or broken cache file is overwritten
+	cache, _ := readServiceListsCache(cachePath)
+	cache[serviceMethod] = cachedServiceList{Urls: urls, UpdatedAt: updatedAt}
+
+	content, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := cachePath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, content, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, cachePath)
+}
+
+// loadCachedServiceList returns cached addresses of a service and the time they were received
+func loadCachedServiceList(cachePath, serviceMethod string) ([]string, time.Time, error) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	cache, err := readServiceListsCache(cachePath)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	cached, ok := cache[serviceMethod]
+	if !ok || len(cached.Urls) == 0 {
+		return nil, time.Time{}, errors.New("There is no cached '" + serviceMethod + "'.")
+	}
+
+	return cached.Urls, cached.UpdatedAt, nil
+}
@@ -1,10 +1,16 @@
 package scalarmWorker
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -162,8 +168,160 @@ func TestInformationServiceShouldErrorNoServiceAvailable(t *testing.T) {
 		t.Errorf("Error expected but got nil")
 	}
 
-	expected_error := "Could not execute request against Scalarm service"
-	if err.Error() != expected_error {
-		t.Errorf("Got: '%v' - Expected '%v'", err.Error(), expected_error)
+	expected_error := "Could not execute request against Scalarm service: "
+	if !strings.HasPrefix(err.Error(), expected_error) || !strings.Contains(err.Error(), "someveryincorrecturl") {
+		t.Errorf("Got: '%v' - Expected '%v' with the cause", err.Error(), expected_error)
+	}
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Errorf("Got: '%#v' - Expected TransportError wrapping url.Error", err)
+	}
+}
+
+func TestInformationServiceShouldRefreshManagersAndKeepLastKnownOnesWhenUnavailable(t *testing.T) {
+	// === GIVEN ===
+	var mutex sync.Mutex
+	available := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if !available {
+			w.WriteHeader(500)
+		} else if r.URL.Path == "/information/experiment_managers" {
+			fmt.Fprintln(w, `["siteB.com", "siteC.com"]`)
+		} else {
+			fmt.Fprintln(w, `["storageB.com"]`)
+		}
+	}))
+	defer server.Close()
+
+	is := setupInformationService(getSimConfig(), getHttpClientMock(server.URL))
+	experimentManagers := NewEndpointPool([]string{"siteA.com"})
+	storageManagers := NewEndpointPool([]string{"storageA.com"})
+
+	// === WHEN ===
+	stopRefreshing := is.RefreshManagers(50*time.Millisecond, experimentManagers, storageManagers)
+	defer stopRefreshing()
+	time.Sleep(200 * time.Millisecond)
+
+	mutex.Lock()
+	available = false
+	mutex.Unlock()
+	time.Sleep(200 * time.Millisecond)
+
+	// === THEN ===
+	if urls := experimentManagers.URLs(); !reflect.DeepEqual(urls, []string{"siteB.com", "siteC.com"}) {
+		t.Errorf("Got: '%v' - Expected '[siteB.com siteC.com]'", urls)
+	}
+
+	if urls := storageManagers.URLs(); !reflect.DeepEqual(urls, []string{"storageB.com"}) {
+		t.Errorf("Got: '%v' - Expected '[storageB.com]'", urls)
+	}
+}
+
+func TestInformationServiceShouldUseCachedManagersWhenUnavailable(t *testing.T) {
+	// === GIVEN ===
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			w.WriteHeader(500)
+			return
+		}
+		fmt.Fprintln(w, `["siteA.com", "siteB.com"]`)
+	}))
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "information_service_cache")
+	defer os.RemoveAll(dir)
+
+	is := setupInformationService(getSimConfig(), getHttpClientMock(server.URL))
+	is.CachePath = dir + "/cache.json"
+	is.CacheMaxAge = time.Hour
+
+	if _, err := is.GetExperimentManagers(); err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+	available = false
+
+	// === WHEN ===
+	experimentManagers, err := is.GetExperimentManagers()
+	_, storageErr := is.GetStorageManagers()
+
+	// === THEN ===
+	if err != nil {
+		t.Errorf("Got: '%v' - Expected nil", err)
+	}
+
+	expected := []string{"siteA.com", "siteB.com"}
+	if !reflect.DeepEqual(expected, experimentManagers) {
+		t.Errorf("Got: '%v' - Expected '%v'", experimentManagers, expected)
+	}
+
+	if storageErr == nil {
+		t.Errorf("Error expected for storage managers which were never cached but got nil")
+	}
+}
+
+func TestInformationServiceShouldNotUseCachedManagersOlderThanMaxAge(t *testing.T) {
+	// === GIVEN ===
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "information_service_cache")
+	defer os.RemoveAll(dir)
+
+	is := setupInformationService(getSimConfig(), getHttpClientMock(server.URL))
+	is.CachePath = dir + "/cache.json"
+	is.CacheMaxAge = time.Hour
+	saveCachedServiceList(is.CachePath, "experiment_managers", []string{"siteA.com"}, time.Now().Add(-2*time.Hour))
+
+	// === WHEN ===
+	experimentManagers, err := is.GetExperimentManagers()
+
+	// === THEN ===
+	if experimentManagers != nil {
+		t.Errorf("Got: '%v' - Expected nil", experimentManagers)
+	}
+
+	expectedError := "Information service response code: 500"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expectedError)
+	}
+}
+
+func TestInformationServiceShouldReturnManagersFromConfigWithoutAskingForThem(t *testing.T) {
+	// === GIVEN ===
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintln(w, `["siteA.com"]`)
+	}))
+	defer server.Close()
+
+	config := getSimConfig()
+	config.ExperimentManagerUrls = []string{"em.scalarm.com"}
+
+	is := setupInformationService(config, getHttpClientMock(server.URL))
+
+	// === WHEN ===
+	experimentManagers, emErr := is.GetExperimentManagers()
+	storageManagers, smErr := is.GetStorageManagers()
+
+	// === THEN ===
+	if emErr != nil || !reflect.DeepEqual(experimentManagers, []string{"em.scalarm.com"}) {
+		t.Errorf("Got: '%v', '%v' - Expected '[em.scalarm.com]'", experimentManagers, emErr)
+	}
+
+	if smErr != nil || !reflect.DeepEqual(storageManagers, []string{"siteA.com"}) {
+		t.Errorf("Got: '%v', '%v' - Expected '[siteA.com]' from Information Service", storageManagers, smErr)
+	}
+
+	if requests != 1 {
+		t.Errorf("Got: %v requests - Expected 1", requests)
 	}
 }
@@ -1,51 +1,42 @@
 package scalarmWorker
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path"
-	"strings"
 	"time"
 )
 
 // IntermediateMonitoring - executes progress monitor of a simulation run and stops when it gets a signal from the main thread
-func (sim SimulationManager) IntermediateMonitoring(messages chan struct{}, finished chan struct{}, codeBaseDir string, experimentManagers []string, simIndex int,
-	simulationDirPath string, client *http.Client, experimentID string) {
-
-	communicationTimeout := 30 * time.Second
-
-	em := ExperimentManager{
-		HttpClient:           client,
-		BaseUrls:             experimentManagers,
-		CommunicationTimeout: communicationTimeout,
-		Config:               sim.Config,
-		ExperimentId:         experimentID}
+// or when ctx is done, a failure of the progress monitor is sent through the finished channel
+func (sim SimulationManager) IntermediateMonitoring(ctx context.Context, messages chan struct{}, finished chan error,
+	codeBaseDir string, reporter ProgressReporter, simIndex int, simulationDirPath string) {
 
 	if _, err := os.Stat(path.Join(codeBaseDir, "progress_monitor")); err == nil {
 		for {
 			progressMonitorCmd := exec.Command("sh", "-c", path.Join(codeBaseDir, "progress_monitor >>_stdout.txt 2>&1"))
 			progressMonitorCmd.Dir = simulationDirPath
 
-			if err = progressMonitorCmd.Run(); err != nil {
-				fmt.Println("[SiM] An error occurred during 'progress_monitor' execution.")
-				fmt.Println("[SiM] Please check if 'progress_monitor' executes correctly on the selected infrastructure.")
-				fmt.Printf("[Fatal error] occured during '%v' execution \n", strings.Join(progressMonitorCmd.Args, " "))
-				fmt.Printf("[Fatal error] %s\n", err.Error())
-				PrintStdoutLog()
-				os.Exit(1)
+			if err = RunCommandContext(ctx, progressMonitorCmd); ctx.Err() != nil {
+				finished <- ctx.Err()
+				return
+			} else if err != nil {
+				finished <- NewAdapterError("progress_monitor", progressMonitorCmd, err, path.Join(simulationDirPath, "_stdout.txt"))
+				return
 			}
 
 			intermediateResults := new(SimulationRunResults)
+			intermediateResultPath := path.Join(simulationDirPath, "intermediate_result.json")
 
-			if _, err := os.Stat("intermediate_result.json"); os.IsNotExist(err) {
+			if _, err := os.Stat(intermediateResultPath); os.IsNotExist(err) {
 				intermediateResults.Status = "error"
 				intermediateResults.Reason = fmt.Sprintf("No 'intermediate_result.json' file found: %s", err.Error())
 			} else {
-				file, err := os.Open("intermediate_result.json")
+				file, err := os.Open(intermediateResultPath)
 
 				if err != nil {
 					intermediateResults.Status = "error"
@@ -71,24 +62,29 @@ func (sim SimulationManager) IntermediateMonitoring(messages chan struct{}, fini
 
 				fmt.Printf("[SiM][progress_info] Results: %v\n", data)
 
-				err = em.PostProgressInfo(simIndex, data)
+				err = reporter.PostProgressInfoContext(ctx, simIndex, data)
 
-				if err != nil {
-					Fatal(err)
+				if ctx.Err() != nil {
+					finished <- ctx.Err()
+					return
+				} else if err != nil {
+					fmt.Printf("[SiM][progress_info] Could not post progress info: %v\n", err)
 				}
 			}
 
-			time.Sleep(10 * time.Second)
 			select {
 			case _ = <-messages:
 				fmt.Printf("[SiM][progress_info] Our work is finished\n")
-				finished <- struct{}{}
+				finished <- nil
+				return
+			case <-ctx.Done():
+				finished <- ctx.Err()
 				return
-			default:
+			case <-time.After(10 * time.Second):
 			}
 		}
 	} else {
 		fmt.Printf("[SiM][progress_info] There is no progress monitor script\n")
-		finished <- struct{}{}
+		finished <- nil
 	}
 }
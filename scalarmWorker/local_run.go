@@ -0,0 +1,199 @@
+package scalarmWorker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// LocalRunResult is a single line of the results file written in the offline mode
+type LocalRunResult struct {
+	SimulationID    int                    `json:"simulation_id"`
+	InputParameters map[string]interface{} `json:"input_parameters"`
+	Status          string                 `json:"status"`
+	Results         interface{}            `json:"results,omitempty"`
+	Reason          string                 `json:"reason,omitempty"`
+}
+
+// localReporter prints information about simulation runs instead of sending it to Experiment Manager
+type localReporter struct{}
+
+func (localReporter) ReportHostInfoContext(ctx context.Context, simulationIndex int, hostInfo *HostInfo) error {
+	fmt.Printf("[SiM][local] Simulation run %v host info: %+v\n", simulationIndex, *hostInfo)
+	return nil
+}
+
+func (localReporter) ReportPerformanceStatsContext(ctx context.Context, simulationIndex int, perfStats *PerformanceStats) error {
+	fmt.Printf("[SiM][local] Simulation run %v performance stats: %+v\n", simulationIndex, *perfStats)
+	return nil
+}
+
+func (localReporter) PostProgressInfoContext(ctx context.Context, simulationIndex int, results url.Values) error {
+	fmt.Printf("[SiM][local] Simulation run %v progress info: %v\n", simulationIndex, results)
+	return nil
+}
+
+// RunLocal executes simulation runs without Scalarm services using the same adapters pipeline as Run.
+// The code base is a zip archive or a directory, input parameters of consecutive simulation runs are read
+// from a JSON array or JSON lines file and results are appended to resultsPath as JSON lines.
+// The code base and directories of simulation runs are extracted into a new 'run_local_*' directory
+// in RootDirPath, so no existing file is overwritten, and they are left there for inspection.
+func (sim SimulationManager) RunLocal(codeBasePath, inputPath, resultsPath string) error {
+	return sim.RunLocalContext(context.Background(), codeBasePath, inputPath, resultsPath)
+}
+
+// RunLocalContext works as RunLocal, once ctx is done the running simulation run is killed and ctx.Err() is returned
+func (sim SimulationManager) RunLocalContext(ctx context.Context, codeBasePath, inputPath, resultsPath string) error {
+	sim.shutdown = newShutdown()
+	stopListening := sim.shutdown.listen()
+	defer stopListening()
+
+	sim.Config.setDefaults()
+
+	inputParameters, err := ReadInputParameters(inputPath)
+	if err != nil {
+		return err
+	}
+
+	runDirPath, err := ioutil.TempDir(sim.RootDirPath, "run_local_")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[SiM] Simulation runs are executed in %v\n", runDirPath)
+
+	codeBaseDir, err := prepareLocalCodeBase(codeBasePath, path.Join(runDirPath, "code_base"))
+	if err != nil {
+		return err
+	}
+
+	resultsFile, err := os.OpenFile(resultsPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer resultsFile.Close()
+
+	failed := 0
+	for i, parameters := range inputParameters {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if sim.shutdown.Requested() {
+			fmt.Printf("[SiM] Exiting due to %v signal\n", sim.shutdown.signal)
+			break
+		}
+
+		simulationIndex := i + 1
+		simulationRun := &SimulationRunConfig{Status: "ok", SimulationID: simulationIndex, InputParameters: parameters}
+
+		fmt.Printf("[SiM] Simulation index: %v/%v\n", simulationIndex, len(inputParameters))
+
+		simulationDirPath := path.Join(runDirPath, fmt.Sprintf("simulation_%v", simulationIndex))
+
+		simulationStart := time.Now()
+		results, _ := sim.processSimulationRun(ctx, localReporter{}, codeBaseDir, simulationDirPath, simulationRun)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		fmt.Printf("[SiM] Simulation run %v finished with status '%v' in %v\n", simulationIndex, results.Status, time.Since(simulationStart))
+		if results.Status != "ok" {
+			failed++
+		}
+
+		line, err := json.Marshal(LocalRunResult{
+			SimulationID:    simulationIndex,
+			InputParameters: parameters,
+			Status:          results.Status,
+			Results:         results.Results,
+			Reason:          results.Reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := resultsFile.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("[SiM] Results written to %v, failed simulation runs: %v\n", resultsPath, failed)
+	return nil
+}
+
+// ReadInputParameters reads input parameters of simulation runs from a JSON array of objects
+// or from JSON objects written one after another, e.g. one per line
+func ReadInputParameters(inputPath string) ([]map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(inputPath)
+	if err != nil {
+		return nil, errors.New("Could not open file " + inputPath + ".")
+	}
+
+	inputParameters := []map[string]interface{}{}
+
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &inputParameters); err != nil {
+			return nil, fmt.Errorf("Incorrect JSON in the file %s: %v", inputPath, err)
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		for {
+			parameters := map[string]interface{}{}
+			if err := decoder.Decode(&parameters); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("Incorrect JSON in the file %s, entry %v: %v", inputPath, len(inputParameters)+1, err)
+			}
+			inputParameters = append(inputParameters, parameters)
+		}
+	}
+
+	if len(inputParameters) == 0 {
+		return nil, errors.New("There are no input parameters in the file " + inputPath + ".")
+	}
+
+	return inputParameters, nil
+}
+
+// prepareLocalCodeBase returns the directory with adapters, a zip archive is extracted into codeBaseDir,
+// which must not exist yet, together with 'simulation_binaries.zip' it contains
+func prepareLocalCodeBase(codeBasePath, codeBaseDir string) (string, error) {
+	info, err := os.Stat(codeBasePath)
+	if err != nil {
+		return "", errors.New("Could not open code base " + codeBasePath + ".")
+	}
+
+	if info.IsDir() {
+		// adapters are executed in directories of simulation runs
+		return filepath.Abs(codeBasePath)
+	}
+
+	if err = os.Mkdir(codeBaseDir, 0777); err != nil {
+		return "", err
+	}
+
+	if err = Extract(codeBasePath, codeBaseDir); err != nil {
+		return "", fmt.Errorf("Could not extract code base %s: %v", codeBasePath, err)
+	}
+
+	binariesPath := path.Join(codeBaseDir, "simulation_binaries.zip")
+	if _, err := os.Stat(binariesPath); err == nil {
+		if err = Extract(binariesPath, codeBaseDir); err != nil {
+			return "", fmt.Errorf("Could not extract simulation_binaries.zip: %v", err)
+		}
+	}
+
+	if err = exec.Command("sh", "-c", fmt.Sprintf("chmod a+x \"%s\"/*", codeBaseDir)).Run(); err != nil {
+		return "", err
+	}
+
+	return codeBaseDir, nil
+}
@@ -0,0 +1,144 @@
+package scalarmWorker
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunLocalShouldExecuteSimulationRunsAndWriteResultsToFile(t *testing.T) {
+	// === GIVEN ===
+	rootDirPath, _ := ioutil.TempDir("", "run_local")
+	defer os.RemoveAll(rootDirPath)
+
+	codeBasePath := path.Join(rootDirPath, "code_base.zip")
+	ioutil.WriteFile(codeBasePath, createCodeBase(t, map[string]string{
+		"executor": "#!/bin/sh\nif grep -q fail input.json; then exit 3; fi\n" +
+			"echo '{\"status\":\"ok\",\"results\":{\"done\":1}}' > output.json\n",
+	}), 0644)
+
+	inputPath := path.Join(rootDirPath, "input.jsonl")
+	ioutil.WriteFile(inputPath, []byte("{\"parameter1\": 1}\n{\"fail\": true}\n"), 0644)
+
+	resultsPath := path.Join(rootDirPath, "results.jsonl")
+
+	sim := SimulationManager{Config: &SimulationManagerConfig{}, RootDirPath: rootDirPath}
+
+	// === WHEN ===
+	err := sim.RunLocal(codeBasePath, inputPath, resultsPath)
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	resultsFile, err := os.Open(resultsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resultsFile.Close()
+
+	results := []LocalRunResult{}
+	scanner := bufio.NewScanner(resultsFile)
+	for scanner.Scan() {
+		result := LocalRunResult{}
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, result)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Got: %v results - Expected 2", len(results))
+	}
+
+	if results[0].Status != "ok" || !reflect.DeepEqual(results[0].Results, map[string]interface{}{"done": 1.0}) {
+		t.Errorf("Got: '%+v' - Expected ok with results", results[0])
+	}
+
+	if results[1].Status != "error" || !strings.Contains(results[1].Reason, "'executor' failed with exit code 3") ||
+		results[1].InputParameters["fail"] != true {
+		t.Errorf("Got: '%+v' - Expected failed executor", results[1])
+	}
+}
+
+func TestReadInputParametersShouldAcceptJSONArray(t *testing.T) {
+	// === GIVEN ===
+	inputFile, _ := ioutil.TempFile("", "input")
+	defer os.Remove(inputFile.Name())
+	inputFile.WriteString(`[{"parameter1": 1}, {"parameter1": 2}]`)
+	inputFile.Close()
+
+	// === WHEN ===
+	inputParameters, err := ReadInputParameters(inputFile.Name())
+
+	// === THEN ===
+	expected := []map[string]interface{}{{"parameter1": 1.0}, {"parameter1": 2.0}}
+	if err != nil || !reflect.DeepEqual(inputParameters, expected) {
+		t.Errorf("Got: '%v', '%v' - Expected '%v'", inputParameters, err, expected)
+	}
+}
+
+func TestRunLocalShouldNotTouchExistingDirectoriesOfWorkingDirectory(t *testing.T) {
+	// === GIVEN ===
+	rootDirPath, _ := ioutil.TempDir("", "run_local")
+	defer os.RemoveAll(rootDirPath)
+
+	codeBasePath := path.Join(rootDirPath, "code_base.zip")
+	ioutil.WriteFile(codeBasePath, createCodeBase(t, map[string]string{
+		"executor": "#!/bin/sh\necho '{\"status\":\"ok\"}' > output.json\n",
+	}), 0644)
+
+	inputPath := path.Join(rootDirPath, "input.json")
+	ioutil.WriteFile(inputPath, []byte(`[{"parameter1": 1}]`), 0644)
+
+	for _, dir := range []string{"code_base", "simulation_1"} {
+		os.Mkdir(path.Join(rootDirPath, dir), 0755)
+		ioutil.WriteFile(path.Join(rootDirPath, dir, "notes.txt"), []byte("mine"), 0644)
+	}
+
+	sim := SimulationManager{Config: &SimulationManagerConfig{}, RootDirPath: rootDirPath}
+
+	// === WHEN ===
+	err := sim.RunLocal(codeBasePath, inputPath, path.Join(rootDirPath, "results.jsonl"))
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	for _, dir := range []string{"code_base", "simulation_1"} {
+		if content, err := ioutil.ReadFile(path.Join(rootDirPath, dir, "notes.txt")); err != nil || string(content) != "mine" {
+			t.Errorf("Got: '%v', '%s' - Expected %s/notes.txt to be left untouched", err, content, dir)
+		}
+	}
+
+	if runDirs, _ := filepath.Glob(path.Join(rootDirPath, "run_local_*", "simulation_1", "output.json")); len(runDirs) != 1 {
+		t.Errorf("Got: '%v' - Expected simulation run directory in a run_local_* directory", runDirs)
+	}
+}
+
+func TestReadInputParametersShouldRejectEmptyJSONArrayAndEmptyJSONLines(t *testing.T) {
+	for _, content := range []string{"[]", " [ ]\n", "", "\n\n"} {
+		// === GIVEN ===
+		inputFile, _ := ioutil.TempFile("", "input")
+		inputFile.WriteString(content)
+		inputFile.Close()
+
+		// === WHEN ===
+		inputParameters, err := ReadInputParameters(inputFile.Name())
+		os.Remove(inputFile.Name())
+
+		// === THEN ===
+		expectedMsg := "There are no input parameters in the file " + inputFile.Name() + "."
+		if err == nil || err.Error() != expectedMsg {
+			t.Errorf("Got: '%v', '%v' for '%q' - Expected '%v'", inputParameters, err, content, expectedMsg)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package scalarmWorker
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -264,8 +265,11 @@ func AggregatePerformanceStats(stats map[int32]*PerformanceStats) *PerformanceSt
 	return agg
 }
 
-// RunProcessMonitoring starts online process monitoring till process ends
-func RunProcessMonitoring(pid int, sim *SimulationManager, em *ExperimentManager, simulationIndex int) {
+// RunProcessMonitoring starts online process monitoring till process ends, i.e. till the done channel is closed,
+// or till ctx is done
+func RunProcessMonitoring(ctx context.Context, pid int, sim *SimulationManager, reporter ProgressReporter, simulationIndex int,
+	done chan struct{}) {
+
 	ps := PsUtil{
 		getHostInfo:    pshost.Info,
 		getCPUInfo:     pscpu.Info,
@@ -280,17 +284,24 @@ func RunProcessMonitoring(pid int, sim *SimulationManager, em *ExperimentManager
 		return
 	}
 
-	err = em.ReportHostInfo(simulationIndex, hostInfo)
+	err = reporter.ReportHostInfoContext(ctx, simulationIndex, hostInfo)
 	if err != nil {
 		fmt.Printf("[SiM] An error occurred during 'ReportHostInfo' - %v\n", err)
 	}
 
 	if sim.Config.MonitoringInterval > 0 {
-		pidExist, pidCheckErr := psproc.PidExists(int32(pid))
 		// initialize an empty map for last stats
 		lastPerformanceStats := make(map[int32]*PerformanceStats)
 
-		for pidExist && pidCheckErr == nil {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			default:
+			}
+
 			// this gets current stats
 			currentPerformanceStats, err := CollectPerformanceStats(pid, &ps)
 			if err != nil {
@@ -303,13 +314,18 @@ func RunProcessMonitoring(pid int, sim *SimulationManager, em *ExperimentManager
 			aggregatedPerformanceStats := AggregatePerformanceStats(lastPerformanceStats)
 
 			// report aggregated stats
-			err = em.ReportPerformanceStats(simulationIndex, aggregatedPerformanceStats)
+			err = reporter.ReportPerformanceStatsContext(ctx, simulationIndex, aggregatedPerformanceStats)
 			if err != nil {
 				fmt.Printf("[SiM] An error occurred during 'ReportPerformanceStats' - %v\n", err)
 			}
 
-			time.Sleep(time.Duration(sim.Config.MonitoringInterval) * time.Second)
-			pidExist, pidCheckErr = psproc.PidExists(int32(pid))
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(sim.Config.MonitoringInterval) * time.Second):
+			}
 		}
 	}
 }
@@ -0,0 +1,497 @@
+package scalarmWorker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kinds of results kept in the outbox
+const (
+	OutboxMarkAsComplete = "mark_as_complete"
+	OutboxProgressInfo   = "progress_info"
+	OutboxOutput         = "output"
+	OutboxStdout         = "stdout"
+)
+
+// errUnknownOutboxEntry is returned when an entry of the outbox cannot be sent by this version of the worker
+var errUnknownOutboxEntry = errors.New("Unknown kind of outbox entry")
+
+// OutboxEntry is a single result of a simulation run waiting to be sent to Scalarm,
+// files of uploads are moved into the outbox directory so they outlive the simulation run directory,
+// FileName keeps their original name under which Storage Manager receives them
+type OutboxEntry struct {
+	ID              string     `json:"id"`
+	Kind            string     `json:"kind"`
+	ExperimentID    string     `json:"experiment_id"`
+	SimulationIndex int        `json:"simulation_index"`
+	Values          url.Values `json:"values,omitempty"`
+	FilePath        string     `json:"file_path,omitempty"`
+	FileName        string     `json:"file_name,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	Attempts        int        `json:"attempts"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// simulationRun identifies the simulation run of the entry, entries of a single run are sent in order
+func (entry *OutboxEntry) simulationRun() string {
+	return entry.ExperimentID + "/" + strconv.Itoa(entry.SimulationIndex)
+}
+
+// originalFileName returns the name of the file before it was journalled,
+// entries added by older versions of the worker do not keep it, so it is restored from the journalled name
+func (entry *OutboxEntry) originalFileName() string {
+	if entry.FileName != "" {
+		return entry.FileName
+	}
+
+	return strings.TrimPrefix(filepath.Base(entry.FilePath), entry.ID+"_")
+}
+
+// Outbox is a journal on disk of results which have not been sent to Scalarm yet. Every entry is a JSON file
+// named with a sequence number, so entries of a simulation run are sent in the order they were added,
+// also after a restart of the worker. An entry is removed when it has been sent or rejected by Scalarm,
+// any other failure postpones the remaining entries of its simulation run. An entry which failed MaxAttempts times
+// or is older than MaxAge is moved to the dead_letter subdirectory for manual inspection.
+type Outbox struct {
+	Dir         string
+	Send        func(ctx context.Context, entry *OutboxEntry) error
+	MaxAttempts int
+	MaxAge      time.Duration
+
+	addMutex  sync.Mutex
+	next      uint64
+	busyMutex sync.Mutex
+	// simulation runs whose entries are being sent
+	busy   map[string]bool
+	failed chan struct{}
+	now    func() time.Time
+}
+
+// OpenOutbox creates the outbox directory if necessary, entries left in it by previous runs of the worker are kept
+func OpenOutbox(dir string, send func(ctx context.Context, entry *OutboxEntry) error) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	outbox := &Outbox{
+		Dir:    dir,
+		Send:   send,
+		next:   1,
+		busy:   map[string]bool{},
+		failed: make(chan struct{}, 1),
+		now:    time.Now,
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		// an entry which was being written when the worker stopped has not been added
+		if strings.HasSuffix(file.Name(), ".tmp") {
+			os.Remove(filepath.Join(dir, file.Name()))
+			continue
+		}
+
+		name := strings.SplitN(strings.SplitN(file.Name(), ".", 2)[0], "_", 2)[0]
+		if sequence, err := strconv.ParseUint(name, 10, 64); err == nil && sequence >= outbox.next {
+			outbox.next = sequence + 1
+		}
+	}
+
+	return outbox, nil
+}
+
+// DeadLetterDir returns the directory of entries which are not sent anymore
+func (o *Outbox) DeadLetterDir() string {
+	return filepath.Join(o.Dir, "dead_letter")
+}
+
+// Add journals the entry, its file is moved into the outbox and its original name is kept in the entry
+func (o *Outbox) Add(entry *OutboxEntry) error {
+	o.addMutex.Lock()
+	defer o.addMutex.Unlock()
+
+	entry.ID = fmt.Sprintf("%020d", o.next)
+	entry.CreatedAt = o.now()
+	o.next++
+
+	if entry.FilePath != "" {
+		if entry.FileName == "" {
+			entry.FileName = filepath.Base(entry.FilePath)
+		}
+
+		journalledPath := filepath.Join(o.Dir, entry.ID+"_"+entry.FileName)
+		if err := moveFile(entry.FilePath, journalledPath); err != nil {
+			return err
+		}
+		entry.FilePath = journalledPath
+	}
+
+	return writeOutboxEntry(filepath.Join(o.Dir, entry.ID+".json"), entry)
+}
+
+// Len returns the number of entries waiting to be sent
+func (o *Outbox) Len() int {
+	paths, _ := filepath.Glob(filepath.Join(o.Dir, "*.json"))
+	return len(paths)
+}
+
+// Flush sends all entries, when some of them fail they are left for the background sender
+// and the first error is returned
+func (o *Outbox) Flush(ctx context.Context) error {
+	return o.retryLater(ctx, o.flush(ctx, ""))
+}
+
+// FlushSimulationRun works as Flush but sends only entries of the given simulation run,
+// so results of one slot do not wait for uploads of other slots
+func (o *Outbox) FlushSimulationRun(ctx context.Context, experimentID string, simulationIndex int) error {
+	entry := OutboxEntry{ExperimentID: experimentID, SimulationIndex: simulationIndex}
+	return o.retryLater(ctx, o.flush(ctx, entry.simulationRun()))
+}
+
+// retryLater wakes up the background sender when entries are left in the outbox
+func (o *Outbox) retryLater(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() == nil {
+		select {
+		case o.failed <- struct{}{}:
+		default:
+		}
+	}
+
+	return err
+}
+
+// errSimulationRunBusy is returned when entries of a simulation run are being sent by another flush
+var errSimulationRunBusy = errors.New("Results of the simulation run are being sent")
+
+// flush sends entries of the given simulation run or of all runs when it is empty
+func (o *Outbox) flush(ctx context.Context, onlySimulationRun string) error {
+	paths, err := filepath.Glob(filepath.Join(o.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	claimed := map[string]bool{}
+	postponed := map[string]bool{}
+	defer o.release(claimed)
+
+	var firstErr error
+	for _, entryPath := range paths {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		entry, err := readOutboxEntry(entryPath)
+		if os.IsNotExist(err) {
+			// it has been sent by another flush
+			continue
+		} else if err != nil {
+			o.moveToDeadLetter(entryPath, &OutboxEntry{}, err)
+			continue
+		}
+
+		simulationRun := entry.simulationRun()
+		if (onlySimulationRun != "" && simulationRun != onlySimulationRun) || postponed[simulationRun] {
+			continue
+		}
+
+		if !claimed[simulationRun] {
+			if !o.claim(simulationRun) {
+				postponed[simulationRun] = true
+				if firstErr == nil {
+					firstErr = errSimulationRunBusy
+				}
+				continue
+			}
+			claimed[simulationRun] = true
+		}
+
+		// the entry could have been sent after it was listed
+		if _, err := os.Stat(entryPath); os.IsNotExist(err) {
+			continue
+		}
+
+		err = o.Send(ctx, entry)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil && !rejected(err) {
+			postponed[simulationRun] = true
+			if firstErr == nil || firstErr == errSimulationRunBusy {
+				firstErr = err
+			}
+			o.recordFailure(entryPath, entry, err)
+			continue
+		} else if err != nil {
+			fmt.Printf("[SiM] Dropping %s of simulation run %v from outbox: %v\n", entry.Kind, entry.SimulationIndex, err)
+		}
+
+		if entry.FilePath != "" {
+			os.Remove(entry.FilePath)
+		}
+		os.Remove(entryPath)
+	}
+
+	return firstErr
+}
+
+func (o *Outbox) claim(simulationRun string) bool {
+	o.busyMutex.Lock()
+	defer o.busyMutex.Unlock()
+
+	if o.busy[simulationRun] {
+		return false
+	}
+	o.busy[simulationRun] = true
+	return true
+}
+
+func (o *Outbox) release(simulationRuns map[string]bool) {
+	o.busyMutex.Lock()
+	defer o.busyMutex.Unlock()
+
+	for simulationRun := range simulationRuns {
+		delete(o.busy, simulationRun)
+	}
+}
+
+// recordFailure counts the failed attempt, the entry is moved to dead letters when it cannot be sent anymore
+func (o *Outbox) recordFailure(entryPath string, entry *OutboxEntry, err error) {
+	entry.Attempts++
+	entry.LastError = err.Error()
+
+	if (o.MaxAttempts > 0 && entry.Attempts >= o.MaxAttempts) || (o.MaxAge > 0 && o.now().Sub(entry.CreatedAt) > o.MaxAge) {
+		o.moveToDeadLetter(entryPath, entry, err)
+		return
+	}
+
+	if err := writeOutboxEntry(entryPath, entry); err != nil {
+		fmt.Printf("[SiM] Could not save attempts of outbox entry %s: %v\n", entry.ID, err)
+	}
+}
+
+// moveToDeadLetter keeps the entry and its file in the dead letter directory
+func (o *Outbox) moveToDeadLetter(entryPath string, entry *OutboxEntry, err error) {
+	if entry.ID == "" {
+		fmt.Printf("[SiM] Outbox entry %s cannot be read, it is moved to %s: %v\n", filepath.Base(entryPath), o.DeadLetterDir(), err)
+	} else {
+		fmt.Printf("[SiM] Giving up sending %s of simulation run %v after %v attempts, it is moved to %s: %v\n",
+			entry.Kind, entry.SimulationIndex, entry.Attempts, o.DeadLetterDir(), err)
+	}
+
+	if mkdirErr := os.MkdirAll(o.DeadLetterDir(), 0700); mkdirErr != nil {
+		fmt.Printf("[SiM] Could not create %s: %v\n", o.DeadLetterDir(), mkdirErr)
+		return
+	}
+
+	if entry.FilePath != "" {
+		// every entry has its own directory, so dead letters of many simulation runs keep their original file names
+		deadDir := filepath.Join(o.DeadLetterDir(), entry.ID)
+		deadPath := filepath.Join(deadDir, entry.originalFileName())
+		if os.MkdirAll(deadDir, 0700) == nil && os.Rename(entry.FilePath, deadPath) == nil {
+			entry.FilePath = deadPath
+		}
+	}
+
+	deadEntryPath := filepath.Join(o.DeadLetterDir(), filepath.Base(entryPath))
+	if entry.ID == "" {
+		// an unreadable entry is moved as it is
+		os.Rename(entryPath, deadEntryPath)
+		return
+	}
+
+	if err := writeOutboxEntry(deadEntryPath, entry); err == nil {
+		os.Remove(entryPath)
+	}
+}
+
+// RunSender retries sending entries in the background after a flush failed, with backoff of the retry policy;
+// the returned function stops the sender
+func (o *Outbox) RunSender(ctx context.Context, policy *RetryPolicy) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		var retry <-chan time.Time
+		failures := 0
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-o.failed:
+				if retry == nil {
+					failures = 1
+					retry = time.After(policy.Backoff(failures))
+				}
+				continue
+			case <-retry:
+			}
+
+			if err := o.flush(ctx, ""); err != nil && ctx.Err() == nil {
+				failures++
+				wait := policy.Backoff(failures)
+				fmt.Printf("[SiM] %v results are kept in outbox: %v, retrying in %v\n", o.Len(), err, wait)
+				retry = time.After(wait)
+			} else {
+				retry = nil
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
+
+func readOutboxEntry(entryPath string) (*OutboxEntry, error) {
+	data, err := ioutil.ReadFile(entryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &OutboxEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// writeOutboxEntry replaces the entry file only when the new content is completely written
+func writeOutboxEntry(entryPath string, entry *OutboxEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tempPath := strings.TrimSuffix(entryPath, ".json") + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, entryPath)
+}
+
+// rejected returns true when Scalarm will never accept the entry, so sending it again makes no sense
+func rejected(err error) bool {
+	switch e := err.(type) {
+	case *ScalarmError, *UploadSizeLimitError:
+		return true
+	case *HTTPStatusError:
+		return e.StatusCode >= 400 && e.StatusCode < 500 && !e.Unauthorized() &&
+			e.StatusCode != http.StatusRequestTimeout && e.StatusCode != http.StatusTooManyRequests
+	}
+
+	return err == errUnknownOutboxEntry || os.IsNotExist(err)
+}
+
+// moveFile renames the file or copies it when it is on another file system
+func moveFile(source, destination string) error {
+	if err := os.Rename(source, destination); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(destination)
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		os.Remove(destination)
+		return err
+	}
+
+	return os.Remove(source)
+}
+
+// outboxSender sends entries of the outbox to Experiment and Storage Managers
+type outboxSender struct {
+	HttpClient           *http.Client
+	ExperimentManagers   *EndpointPool
+	StorageManager       *StorageManager
+	CommunicationTimeout time.Duration
+	Config               *SimulationManagerConfig
+}
+
+func (s *outboxSender) send(ctx context.Context, entry *OutboxEntry) error {
+	em := &ExperimentManager{
+		HttpClient:           s.HttpClient,
+		Endpoints:            s.ExperimentManagers,
+		CommunicationTimeout: s.CommunicationTimeout,
+		Config:               s.Config,
+		ExperimentId:         entry.ExperimentID}
+
+	switch entry.Kind {
+	case OutboxMarkAsComplete:
+		_, err := em.MarkSimulationRunAsCompleteContext(ctx, entry.SimulationIndex, entry.Values)
+		return err
+	case OutboxProgressInfo:
+		return em.PostProgressInfoContext(ctx, entry.SimulationIndex, entry.Values)
+	case OutboxOutput:
+		fmt.Printf("[SiM] Uploading 'output.tar.gz' of simulation run %v ...\n", entry.SimulationIndex)
+		return s.StorageManager.UploadSimulationRunOutputContext(ctx, entry.ExperimentID, entry.SimulationIndex,
+			entry.FilePath, entry.originalFileName())
+	case OutboxStdout:
+		fmt.Printf("[SiM] Uploading STDOUT of simulation run %v ...\n", entry.SimulationIndex)
+		return s.StorageManager.UploadSimulationRunStdoutContext(ctx, entry.ExperimentID, entry.SimulationIndex,
+			entry.FilePath, entry.originalFileName())
+	}
+
+	return errUnknownOutboxEntry
+}
+
+// outboxReporter posts progress info of a simulation run through the outbox,
+// so it is not overtaken by results of the run which wait in the outbox
+type outboxReporter struct {
+	*ExperimentManager
+	outbox *Outbox
+}
+
+func (r *outboxReporter) PostProgressInfoContext(ctx context.Context, simulationIndex int, results url.Values) error {
+	entry := &OutboxEntry{
+		Kind:            OutboxProgressInfo,
+		ExperimentID:    r.ExperimentId,
+		SimulationIndex: simulationIndex,
+		Values:          results}
+
+	if err := r.outbox.Add(entry); err != nil {
+		return err
+	}
+
+	if err := r.outbox.FlushSimulationRun(ctx, r.ExperimentId, simulationIndex); err != nil && ctx.Err() == nil {
+		fmt.Printf("[SiM][progress_info] Progress info is kept in outbox: %v\n", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+package scalarmWorker
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/scalarm/scalarm_simulation_manager_go/fakeScalarm"
+)
+
+func TestOutboxShouldKeepEntriesUntilTheyAreSentAlsoAfterReopening(t *testing.T) {
+	// === GIVEN ===
+	dir, _ := ioutil.TempDir("", "scalarm_outbox")
+	defer os.RemoveAll(dir)
+
+	stdoutPath := path.Join(dir, "_stdout.txt")
+	ioutil.WriteFile(stdoutPath, []byte("computing"), 0600)
+
+	unreachable := func(ctx context.Context, entry *OutboxEntry) error {
+		return &TransportError{Err: errors.New("connection refused")}
+	}
+	outbox, _ := OpenOutbox(path.Join(dir, "outbox"), unreachable)
+	outbox.Add(&OutboxEntry{Kind: OutboxMarkAsComplete, ExperimentID: "1", SimulationIndex: 2, Values: url.Values{"status": {"ok"}}})
+	outbox.Add(&OutboxEntry{Kind: OutboxStdout, ExperimentID: "1", SimulationIndex: 2, FilePath: stdoutPath})
+
+	// === WHEN ===
+	flushErr := outbox.Flush(context.Background())
+
+	sent := []string{}
+	reopened, err := OpenOutbox(path.Join(dir, "outbox"), func(ctx context.Context, entry *OutboxEntry) error {
+		content := entry.Values.Get("status")
+		if entry.FilePath != "" {
+			data, _ := ioutil.ReadFile(entry.FilePath)
+			content = string(data)
+		}
+		sent = append(sent, entry.Kind+":"+content)
+		return nil
+	})
+	if err == nil {
+		err = reopened.Flush(context.Background())
+	}
+
+	// === THEN ===
+	if _, ok := flushErr.(*TransportError); !ok {
+		t.Errorf("Got: '%v' - Expected TransportError", flushErr)
+	}
+
+	if _, statErr := os.Stat(stdoutPath); !os.IsNotExist(statErr) {
+		t.Errorf("Got: '%v' - Expected _stdout.txt to be moved into outbox", statErr)
+	}
+
+	expected := []string{"mark_as_complete:ok", "stdout:computing"}
+	if err != nil || !reflect.DeepEqual(sent, expected) {
+		t.Errorf("Got: '%v', '%v' - Expected nil, '%v'", err, sent, expected)
+	}
+
+	if files, _ := ioutil.ReadDir(path.Join(dir, "outbox")); len(files) != 0 || reopened.Len() != 0 {
+		t.Errorf("Got: %v files - Expected empty outbox", len(files))
+	}
+}
+
+func TestOutboxShouldDropEntriesRejectedByScalarm(t *testing.T) {
+	// === GIVEN ===
+	dir, _ := ioutil.TempDir("", "scalarm_outbox")
+	defer os.RemoveAll(dir)
+
+	sent := []int{}
+	outbox, _ := OpenOutbox(dir, func(ctx context.Context, entry *OutboxEntry) error {
+		sent = append(sent, entry.SimulationIndex)
+		if entry.SimulationIndex == 1 {
+			return &ScalarmError{Status: "error", Reason: "Simulation run not found"}
+		}
+		return nil
+	})
+	outbox.Add(&OutboxEntry{Kind: OutboxMarkAsComplete, ExperimentID: "1", SimulationIndex: 1})
+	outbox.Add(&OutboxEntry{Kind: OutboxMarkAsComplete, ExperimentID: "1", SimulationIndex: 2})
+
+	// === WHEN ===
+	err := outbox.Flush(context.Background())
+
+	// === THEN ===
+	if err != nil || !reflect.DeepEqual(sent, []int{1, 2}) || outbox.Len() != 0 {
+		t.Errorf("Got: '%v', '%v', %v entries - Expected nil, '[1 2]', 0 entries", err, sent, outbox.Len())
+	}
+}
+
+func TestOutboxShouldMoveEntriesToDeadLetterAfterMaxAttempts(t *testing.T) {
+	// === GIVEN ===
+	dir, _ := ioutil.TempDir("", "scalarm_outbox")
+	defer os.RemoveAll(dir)
+
+	outputPath := path.Join(dir, "output.tar.gz")
+	ioutil.WriteFile(outputPath, []byte("output"), 0600)
+
+	outbox, _ := OpenOutbox(path.Join(dir, "outbox"), func(ctx context.Context, entry *OutboxEntry) error {
+		return &HTTPStatusError{Service: "Storage manager", StatusCode: 500}
+	})
+	outbox.MaxAttempts = 3
+	outbox.Add(&OutboxEntry{Kind: OutboxOutput, ExperimentID: "1", SimulationIndex: 2, FilePath: outputPath})
+
+	// === WHEN ===
+	errs := []error{}
+	for i := 0; i < 3; i++ {
+		errs = append(errs, outbox.Flush(context.Background()))
+	}
+
+	// === THEN ===
+	if errs[1] == nil {
+		t.Errorf("Got: '%v' - Expected HTTPStatusError before the last attempt", errs[1])
+	}
+
+	if outbox.Len() != 0 {
+		t.Errorf("Got: '%v' - Expected '%v' entries in outbox", outbox.Len(), 0)
+	}
+
+	deadEntries, _ := filepath.Glob(filepath.Join(outbox.DeadLetterDir(), "*.json"))
+	if len(deadEntries) != 1 {
+		t.Fatalf("Got: '%v' - Expected '%v' dead letters", len(deadEntries), 1)
+	}
+
+	entry, err := readOutboxEntry(deadEntries[0])
+	if err != nil || entry.Attempts != 3 || entry.LastError == "" {
+		t.Errorf("Got: '%v', '%+v' - Expected entry with 3 attempts and the last error", err, entry)
+	}
+
+	if data, err := ioutil.ReadFile(entry.FilePath); err != nil || string(data) != "output" || filepath.Base(entry.FilePath) != "output.tar.gz" {
+		t.Errorf("Got: '%v', '%s' - Expected output.tar.gz kept with the dead letter", err, data)
+	}
+}
+
+func TestOutboxShouldMoveEntriesOlderThanMaxAgeToDeadLetter(t *testing.T) {
+	// === GIVEN ===
+	dir, _ := ioutil.TempDir("", "scalarm_outbox")
+	defer os.RemoveAll(dir)
+
+	outbox, _ := OpenOutbox(dir, func(ctx context.Context, entry *OutboxEntry) error {
+		return &HTTPStatusError{Service: "Experiment manager", StatusCode: 401}
+	})
+	outbox.MaxAge = time.Hour
+	outbox.Add(&OutboxEntry{Kind: OutboxMarkAsComplete, ExperimentID: "1", SimulationIndex: 2})
+
+	// === WHEN ===
+	firstErr := outbox.Flush(context.Background())
+	outbox.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	outbox.Flush(context.Background())
+
+	// === THEN ===
+	if firstErr == nil {
+		t.Errorf("Got: '%v' - Expected HTTPStatusError", firstErr)
+	}
+
+	deadEntries, _ := filepath.Glob(filepath.Join(outbox.DeadLetterDir(), "*.json"))
+	if outbox.Len() != 0 || len(deadEntries) != 1 {
+		t.Errorf("Got: '%v', '%v' - Expected '%v', '%v'", outbox.Len(), len(deadEntries), 0, 1)
+	}
+}
+
+func TestOutboxShouldMoveCorruptEntriesToDeadLetter(t *testing.T) {
+	// === GIVEN ===
+	dir, _ := ioutil.TempDir("", "scalarm_outbox")
+	defer os.RemoveAll(dir)
+
+	sent := 0
+	outbox, _ := OpenOutbox(dir, func(ctx context.Context, entry *OutboxEntry) error {
+		sent++
+		return nil
+	})
+	ioutil.WriteFile(filepath.Join(dir, "0001_corrupt.json"), []byte(`{"kind":"mark_as_`), 0600)
+
+	// === WHEN ===
+	err := outbox.Flush(context.Background())
+
+	// === THEN ===
+	if err != nil || sent != 0 || outbox.Len() != 0 {
+		t.Errorf("Got: '%v', %v sent, %v entries - Expected nil, 0 sent, 0 entries", err, sent, outbox.Len())
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(outbox.DeadLetterDir(), "0001_corrupt.json")); err != nil || string(data) != `{"kind":"mark_as_` {
+		t.Errorf("Got: '%v', '%s' - Expected the corrupt entry kept as it is in dead letters", err, data)
+	}
+}
+
+func TestOutboxShouldFlushOnlyEntriesOfTheGivenSimulationRun(t *testing.T) {
+	// === GIVEN ===
+	dir, _ := ioutil.TempDir("", "scalarm_outbox")
+	defer os.RemoveAll(dir)
+
+	sent := []int{}
+	outbox, _ := OpenOutbox(dir, func(ctx context.Context, entry *OutboxEntry) error {
+		if entry.SimulationIndex == 1 {
+			return &TransportError{Err: errors.New("connection refused")}
+		}
+		sent = append(sent, entry.SimulationIndex)
+		return nil
+	})
+	outbox.Add(&OutboxEntry{Kind: OutboxOutput, ExperimentID: "1", SimulationIndex: 1})
+	outbox.Add(&OutboxEntry{Kind: OutboxMarkAsComplete, ExperimentID: "1", SimulationIndex: 2})
+	outbox.Add(&OutboxEntry{Kind: OutboxMarkAsComplete, ExperimentID: "1", SimulationIndex: 3})
+
+	// === WHEN ===
+	err := outbox.FlushSimulationRun(context.Background(), "1", 2)
+	allErr := outbox.Flush(context.Background())
+
+	// === THEN ===
+	if err != nil {
+		t.Errorf("Got: '%v' - Expected nil", err)
+	}
+
+	if _, ok := allErr.(*TransportError); !ok {
+		t.Errorf("Got: '%v' - Expected TransportError", allErr)
+	}
+
+	if expected := []int{2, 3}; !reflect.DeepEqual(sent, expected) {
+		t.Errorf("Got: '%v' - Expected '%v'", sent, expected)
+	}
+
+	if outbox.Len() != 1 {
+		t.Errorf("Got: '%v' - Expected '%v' entries in outbox", outbox.Len(), 1)
+	}
+}
+
+func TestOutboxShouldUploadFilesUnderTheirOriginalNames(t *testing.T) {
+	// === GIVEN ===
+	dir, _ := ioutil.TempDir("", "scalarm_outbox")
+	defer os.RemoveAll(dir)
+
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{ID: "1", InputParameters: []map[string]interface{}{{"parameter1": 1}}})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	// the fake accepts results only of simulation runs which have been sent
+	http.Get(server.URL + "/experiments/1/next_simulation")
+
+	outputPath := path.Join(dir, "output.tar.gz")
+	ioutil.WriteFile(outputPath, []byte("output"), 0600)
+	stdoutPath := path.Join(dir, "_stdout.txt")
+	ioutil.WriteFile(stdoutPath, []byte("computing"), 0600)
+
+	config := getSimConfig()
+	sender := &outboxSender{
+		HttpClient:         getHttpClientMock(server.URL),
+		ExperimentManagers: NewEndpointPool([]string{"em.scalarm.com"}),
+		StorageManager:     getStorageManagerMock(server.URL, config),
+		Config:             config,
+	}
+
+	outbox, _ := OpenOutbox(path.Join(dir, "outbox"), sender.send)
+	outbox.Add(&OutboxEntry{Kind: OutboxOutput, ExperimentID: "1", SimulationIndex: 1, FilePath: outputPath})
+	outbox.Add(&OutboxEntry{Kind: OutboxStdout, ExperimentID: "1", SimulationIndex: 1, FilePath: stdoutPath})
+
+	// === WHEN ===
+	reopened, err := OpenOutbox(path.Join(dir, "outbox"), sender.send)
+	if err == nil {
+		err = reopened.Flush(context.Background())
+	}
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	uploaded := []string{}
+	for _, upload := range fake.Uploads() {
+		uploaded = append(uploaded, upload.Kind+":"+upload.FileName+":"+string(upload.Content))
+	}
+
+	expected := []string{"output:output.tar.gz:output", "stdout:_stdout.txt:computing"}
+	if !reflect.DeepEqual(uploaded, expected) {
+		t.Errorf("Got: '%v' - Expected '%v'", uploaded, expected)
+	}
+}
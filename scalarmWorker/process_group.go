@@ -0,0 +1,17 @@
+package scalarmWorker
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// SetProcessGroup makes the command a leader of a new process group,
+// so the whole process tree started by the command can be signalled at once
+func SetProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// SignalProcessGroup sends the signal to all processes of the group led by the given pid
+func SignalProcessGroup(pid int, signal syscall.Signal) error {
+	return syscall.Kill(-pid, signal)
+}
@@ -0,0 +1,184 @@
+package scalarmWorker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"time"
+)
+
+// progressLogInterval is the min time between log messages about progress of an upload
+const progressLogInterval = 10 * time.Second
+
+// RequestBody is a payload of a request to a Scalarm service which can be sent more than once:
+// every retry and every replica of the service receives a fresh reader with the full payload
+type RequestBody interface {
+	// Open returns a new reader positioned at the beginning of the payload
+	Open() (io.ReadCloser, error)
+	// Size returns the length of the payload in bytes or -1 when it is not known in advance
+	Size() int64
+}
+
+// BytesBody is a payload kept in memory, e.g. an url encoded form
+type BytesBody []byte
+
+func (body BytesBody) Open() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(body)), nil
+}
+
+func (body BytesBody) Size() int64 {
+	return int64(len(body))
+}
+
+// FileBody is a payload read from a file, the file is reopened for every attempt instead of being loaded into memory
+type FileBody struct {
+	Path string
+}
+
+func (body FileBody) Open() (io.ReadCloser, error) {
+	return os.Open(body.Path)
+}
+
+func (body FileBody) Size() int64 {
+	info, err := os.Stat(body.Path)
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
+// FileSectionBody is a part of a file, e.g. a chunk of an upload, read directly from the file for every attempt
+type FileSectionBody struct {
+	Path   string
+	Offset int64
+	Length int64
+}
+
+func (body FileSectionBody) Open() (io.ReadCloser, error) {
+	file, err := os.Open(body.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sectionReadCloser{io.NewSectionReader(file, body.Offset, body.Length), file}, nil
+}
+
+func (body FileSectionBody) Size() int64 {
+	return body.Length
+}
+
+// sectionReadCloser reads a section of a file and closes the file
+type sectionReadCloser struct {
+	*io.SectionReader
+	file *os.File
+}
+
+func (r *sectionReadCloser) Close() error {
+	return r.file.Close()
+}
+
+// MultipartFileBody is a multipart form with a single file field, e.g. output.tar.gz uploaded to Storage Manager;
+// the form is streamed from the file while being sent, so the file is never loaded into memory
+type MultipartFileBody struct {
+	FieldName string
+	Path      string
+	// file name sent in the form, it does not have to be the name of Path
+	FileName string
+	boundary string
+}
+
+// NewMultipartFileBody creates a form sending the file as the given field named fileName
+func NewMultipartFileBody(fieldName, filePath, fileName string) *MultipartFileBody {
+	return &MultipartFileBody{
+		FieldName: fieldName,
+		Path:      filePath,
+		FileName:  fileName,
+		boundary:  multipart.NewWriter(ioutil.Discard).Boundary(),
+	}
+}
+
+// ContentType returns the content type of the form with its boundary
+func (body *MultipartFileBody) ContentType() string {
+	return "multipart/form-data; boundary=" + body.boundary
+}
+
+func (body *MultipartFileBody) Open() (io.ReadCloser, error) {
+	file, err := os.Open(body.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		defer file.Close()
+		content := &progressReader{reader: file, name: body.FileName, size: info.Size(), lastLog: time.Now()}
+		// the reading side gets the error, it is nil when the whole form has been written
+		writer.CloseWithError(body.writeForm(writer, content))
+	}()
+
+	return reader, nil
+}
+
+// Size returns the length of the form: the file size and the multipart envelope around it
+func (body *MultipartFileBody) Size() int64 {
+	info, err := os.Stat(body.Path)
+	if err != nil {
+		return -1
+	}
+
+	envelope := &bytes.Buffer{}
+	if err := body.writeForm(envelope, &bytes.Buffer{}); err != nil {
+		return -1
+	}
+
+	return int64(envelope.Len()) + info.Size()
+}
+
+// writeForm writes the form with the file field filled with the content
+func (body *MultipartFileBody) writeForm(w io.Writer, content io.Reader) error {
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(body.boundary); err != nil {
+		return err
+	}
+
+	part, err := writer.CreateFormFile(body.FieldName, body.FileName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(part, content); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// progressReader logs how much of an uploaded file has been sent
+type progressReader struct {
+	reader  io.Reader
+	name    string
+	size    int64
+	sent    int64
+	lastLog time.Time
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.sent += int64(n)
+
+	if err == io.EOF || time.Since(r.lastLog) >= progressLogInterval {
+		fmt.Printf("[SiM] Uploaded %v of %v bytes of '%s'\n", r.sent, r.size, r.name)
+		r.lastLog = time.Now()
+	}
+
+	return n, err
+}
@@ -0,0 +1,196 @@
+package scalarmWorker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// default values of the retry policy used when they are not set in the config
+const (
+	defaultRetryMaxAttempts    = 5
+	defaultRetryInitialBackoff = 1 * time.Second
+	defaultRetryMaxBackoff     = 30 * time.Second
+)
+
+var defaultRetryStatusCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// RetryPolicy describes how requests to Scalarm services are retried on transport errors and on retryable
+// response codes: with exponentially growing backoff, full jitter and honoring Retry-After headers
+type RetryPolicy struct {
+	// max number of attempts of a single request, 0 means retrying until the communication timeout;
+	// NewRetryPolicy never leaves it 0, it uses the default of 5 attempts when retry_max_attempts is not set
+	MaxAttempts      int
+	InitialBackoff   time.Duration
+	MaxBackoff       time.Duration
+	RetryStatusCodes []int
+
+	sleep  func(context.Context, time.Duration) error
+	random func(int64) int64
+}
+
+// NewRetryPolicy creates a retry policy from the config, missing values are replaced with defaults
+func NewRetryPolicy(config *SimulationManagerConfig) *RetryPolicy {
+	policy := &RetryPolicy{
+		MaxAttempts:      config.RetryMaxAttempts,
+		InitialBackoff:   time.Duration(config.RetryInitialBackoff) * time.Second,
+		MaxBackoff:       time.Duration(config.RetryMaxBackoff) * time.Second,
+		RetryStatusCodes: config.RetryStatusCodes,
+		sleep:            sleepContext,
+		random:           rand.Int63n,
+	}
+
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultRetryMaxAttempts
+	}
+
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaultRetryInitialBackoff
+	}
+
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultRetryMaxBackoff
+	}
+
+	if policy.RetryStatusCodes == nil {
+		policy.RetryStatusCodes = defaultRetryStatusCodes
+	}
+
+	return policy
+}
+
+// Backoff returns a random time to wait before the next attempt,
+// it is chosen from [0, min(MaxBackoff, InitialBackoff * 2^(attempt-1)))
+func (policy *RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := policy.InitialBackoff
+	for i := 1; i < attempt && backoff < policy.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+
+	if backoff <= 0 {
+		return 0
+	}
+
+	return time.Duration(policy.random(int64(backoff)))
+}
+
+// Retryable returns true when a response with the given status code should be retried
+func (policy *RetryPolicy) Retryable(statusCode int) bool {
+	for _, code := range policy.RetryStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Do executes the request until it succeeds, the attempts are exhausted or the next attempt would exceed the timeout;
+// when retries are exhausted on a retryable status code the last response is returned.
+// The body is reopened with GetBody before every retry, a request with a body which cannot be reopened is sent once
+func (policy *RetryPolicy) Do(client *http.Client, request *http.Request, timeout time.Duration) (*http.Response, error) {
+	return policy.DoContext(request.Context(), client, request, timeout)
+}
+
+// DoContext works as Do, the request is bound to ctx and no more attempts are made once ctx is done
+func (policy *RetryPolicy) DoContext(ctx context.Context, client *http.Client, request *http.Request,
+	timeout time.Duration) (*http.Response, error) {
+
+	deadline := time.Now().Add(timeout)
+	request = request.WithContext(ctx)
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && request.GetBody != nil {
+			// the previous attempt consumed the body, every attempt has to send the full payload
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			request.Body = body
+		}
+
+		resp, err := client.Do(request)
+
+		var wait time.Duration
+		if ctx.Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		} else if err != nil {
+			fmt.Printf("[SiM] %v\n", err)
+			wait = policy.Backoff(attempt)
+		} else if policy.Retryable(resp.StatusCode) {
+			fmt.Printf("[SiM] Response code: %v\n", resp.StatusCode)
+			wait = policy.Backoff(attempt)
+			if retryAfter, ok := RetryAfter(resp, time.Now()); ok {
+				wait = retryAfter
+			}
+		} else {
+			return resp, nil
+		}
+
+		if (policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts) || time.Now().Add(wait).After(deadline) ||
+			!replayable(request) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		fmt.Printf("[SiM] Retrying in %v (attempt %v)\n", wait, attempt+1)
+		if err := policy.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// replayable returns true when the request can be sent again: it has no body or the body can be reopened
+func replayable(request *http.Request) bool {
+	return request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+}
+
+// sleepContext waits for the given time, it returns an error when ctx is done in the meantime
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RetryAfter returns the time to wait given in the Retry-After header as seconds or as HTTP date
+func RetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,244 @@
+package scalarmWorker
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getRetryPolicyMock(sleeps *[]time.Duration) *RetryPolicy {
+	policy := NewRetryPolicy(&SimulationManagerConfig{RetryMaxAttempts: 3})
+	policy.sleep = func(ctx context.Context, d time.Duration) error {
+		*sleeps = append(*sleeps, d)
+		return nil
+	}
+	policy.random = func(n int64) int64 { return n - 1 }
+	return policy
+}
+
+func TestRetryPolicyShouldRetryUnavailableServiceHonoringRetryAfter(t *testing.T) {
+	// === GIVEN ===
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(503)
+		} else if attempts == 2 {
+			w.WriteHeader(429)
+		} else {
+			w.WriteHeader(200)
+		}
+	}))
+	defer server.Close()
+
+	sleeps := []time.Duration{}
+	policy := getRetryPolicyMock(&sleeps)
+	request, _ := http.NewRequest("GET", server.URL, nil)
+
+	// === WHEN ===
+	resp, err := policy.Do(http.DefaultClient, request, time.Minute)
+
+	// === THEN ===
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Got: '%v', '%v' - Expected response code 200", resp, err)
+	}
+	resp.Body.Close()
+
+	expected := []time.Duration{7 * time.Second, 2*time.Second - 1}
+	if len(sleeps) != 2 || sleeps[0] != expected[0] || sleeps[1] != expected[1] {
+		t.Errorf("Got: '%v' - Expected '%v'", sleeps, expected)
+	}
+}
+
+func TestRetryPolicyShouldReturnLastResponseWhenAttemptsAreExhausted(t *testing.T) {
+	// === GIVEN ===
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(502)
+	}))
+	defer server.Close()
+
+	sleeps := []time.Duration{}
+	policy := getRetryPolicyMock(&sleeps)
+	request, _ := http.NewRequest("GET", server.URL, nil)
+
+	// === WHEN ===
+	resp, err := policy.Do(http.DefaultClient, request, time.Minute)
+
+	// === THEN ===
+	if err != nil || resp.StatusCode != 502 {
+		t.Fatalf("Got: '%v', '%v' - Expected response code 502", resp, err)
+	}
+	resp.Body.Close()
+
+	if attempts != 3 {
+		t.Errorf("Got: %v attempts - Expected 3", attempts)
+	}
+}
+
+func TestRetryPolicyShouldNotRetryWhenRetryAfterExceedsTimeout(t *testing.T) {
+	// === GIVEN ===
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	sleeps := []time.Duration{}
+	policy := getRetryPolicyMock(&sleeps)
+	request, _ := http.NewRequest("GET", server.URL, nil)
+
+	// === WHEN ===
+	resp, _ := policy.Do(http.DefaultClient, request, time.Minute)
+
+	// === THEN ===
+	resp.Body.Close()
+	if attempts != 1 || len(sleeps) != 0 {
+		t.Errorf("Got: %v attempts - Expected 1", attempts)
+	}
+}
+
+func TestRetryPolicyShouldResendFullBodyOnEveryAttempt(t *testing.T) {
+	// === GIVEN ===
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			w.WriteHeader(503)
+		}
+	}))
+	defer server.Close()
+
+	sleeps := []time.Duration{}
+	policy := getRetryPolicyMock(&sleeps)
+	request, _ := http.NewRequest("POST", server.URL, strings.NewReader("status=ok&result=%7B%7D"))
+
+	// === WHEN ===
+	resp, err := policy.Do(http.DefaultClient, request, time.Minute)
+
+	// === THEN ===
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Got: '%v', '%v' - Expected response code 200", resp, err)
+	}
+	resp.Body.Close()
+
+	expected := []string{"status=ok&result=%7B%7D", "status=ok&result=%7B%7D", "status=ok&result=%7B%7D"}
+	if !reflect.DeepEqual(bodies, expected) {
+		t.Errorf("Got: '%v' - Expected '%v'", bodies, expected)
+	}
+}
+
+func TestRetryPolicyShouldNotRetryRequestWithBodyWhichCannotBeReopened(t *testing.T) {
+	// === GIVEN ===
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	sleeps := []time.Duration{}
+	policy := getRetryPolicyMock(&sleeps)
+	request, _ := http.NewRequest("POST", server.URL, ioutil.NopCloser(strings.NewReader("status=ok")))
+
+	// === WHEN ===
+	resp, err := policy.Do(http.DefaultClient, request, time.Minute)
+
+	// === THEN ===
+	if err != nil || resp.StatusCode != 503 {
+		t.Fatalf("Got: '%v', '%v' - Expected response code 503", resp, err)
+	}
+	resp.Body.Close()
+
+	if attempts != 1 {
+		t.Errorf("Got: %v attempts - Expected 1", attempts)
+	}
+}
+
+func TestRetryPolicyShouldStopRetryingWhenContextIsCanceled(t *testing.T) {
+	// === GIVEN ===
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := NewRetryPolicy(&SimulationManagerConfig{RetryInitialBackoff: 60})
+	policy.random = func(n int64) int64 { return n - 1 }
+	request, _ := http.NewRequest("GET", server.URL, nil)
+
+	// === WHEN ===
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	resp, err := policy.DoContext(ctx, http.DefaultClient, request, time.Hour)
+
+	// === THEN ===
+	if resp != nil || err != context.Canceled {
+		t.Errorf("Got: '%v', '%v' - Expected '%v'", resp, err, context.Canceled)
+	}
+
+	if attempts != 1 || time.Since(start) > 10*time.Second {
+		t.Errorf("Got: %v attempts in %v - Expected 1 attempt interrupted by cancellation", attempts, time.Since(start))
+	}
+}
+
+func TestRetryPolicyShouldUseDefaultMaxAttemptsWhenItIsZeroInConfig(t *testing.T) {
+	// === GIVEN ===
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	policy := NewRetryPolicy(&SimulationManagerConfig{RetryMaxAttempts: 0})
+	policy.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	request, _ := http.NewRequest("GET", server.URL, nil)
+
+	// === WHEN ===
+	resp, err := policy.Do(http.DefaultClient, request, time.Hour)
+
+	// === THEN ===
+	if err != nil || resp.StatusCode != 503 {
+		t.Fatalf("Got: '%v', '%v' - Expected response code 503", resp, err)
+	}
+	resp.Body.Close()
+
+	if policy.MaxAttempts != defaultRetryMaxAttempts || attempts != defaultRetryMaxAttempts {
+		t.Errorf("Got: %v max attempts, %v attempts - Expected %v", policy.MaxAttempts, attempts, defaultRetryMaxAttempts)
+	}
+}
+
+func TestRetryPolicyBackoffShouldGrowExponentiallyUpToMaxBackoff(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	policy.random = func(n int64) int64 { return n }
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, backoff := range expected {
+		if policy.Backoff(i+1) != backoff {
+			t.Errorf("Got: '%v' - Expected '%v' for attempt %v", policy.Backoff(i+1), backoff, i+1)
+		}
+	}
+}
+
+func TestRetryAfterShouldAcceptHttpDate(t *testing.T) {
+	now := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+	resp := &http.Response{Header: http.Header{"Retry-After": {"Sat, 01 Apr 2017 12:00:30 GMT"}}}
+
+	wait, ok := RetryAfter(resp, now)
+	if !ok || wait != 30*time.Second {
+		t.Errorf("Got: '%v', '%v' - Expected '30s'", wait, ok)
+	}
+}
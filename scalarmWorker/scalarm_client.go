@@ -0,0 +1,299 @@
+package scalarmWorker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ScalarmRequest describes a request to a method of a Scalarm service, e.g. 'experiments/1/next_simulation'
+type ScalarmRequest struct {
+	Method        string
+	ServiceMethod string
+	Body          RequestBody
+	ContentType   string
+	// additional headers, e.g. a checksum of the body
+	Header http.Header
+}
+
+// newHTTPRequest creates a request to the given url, its body can be reopened with GetBody before every retry
+func (request ScalarmRequest) newHTTPRequest(requestUrl string) (*http.Request, error) {
+	req, err := http.NewRequest(request.Method, requestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range request.Header {
+		req.Header[name] = values
+	}
+
+	if request.Body == nil {
+		return req, nil
+	}
+
+	body, err := request.Body.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = body
+	req.GetBody = request.Body.Open
+	if size := request.Body.Size(); size > 0 {
+		req.ContentLength = size
+	}
+	req.Header.Set("Content-Type", request.ContentType)
+
+	return req, nil
+}
+
+// NewFormRequest creates a request sending url encoded form values
+func NewFormRequest(method, serviceMethod string, values url.Values) ScalarmRequest {
+	return ScalarmRequest{
+		Method:        method,
+		ServiceMethod: serviceMethod,
+		Body:          BytesBody(values.Encode()),
+		ContentType:   "application/x-www-form-urlencoded",
+	}
+}
+
+// StatusResponse is the part of JSON answers of Scalarm services describing the outcome of a request
+type StatusResponse struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// Err returns ScalarmError when the status is given and it is not one of the accepted ones ('ok' by default)
+func (response StatusResponse) Err(acceptedStatuses ...string) error {
+	if response.Status == "" {
+		return nil
+	}
+
+	if len(acceptedStatuses) == 0 {
+		acceptedStatuses = []string{"ok"}
+	}
+
+	for _, status := range acceptedStatuses {
+		if response.Status == status {
+			return nil
+		}
+	}
+
+	return &ScalarmError{Status: response.Status, Reason: response.Reason}
+}
+
+// TransportError is returned when a request could not be executed against any replica of a Scalarm service,
+// Err is the last problem encountered
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	if e.Err == nil {
+		return "Could not execute request against Scalarm service"
+	}
+	return "Could not execute request against Scalarm service: " + e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatusError is returned when a Scalarm service responds with an unexpected status code
+type HTTPStatusError struct {
+	Service    string
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return e.Service + " response code: " + strconv.Itoa(e.StatusCode)
+}
+
+// Unauthorized returns true when the service rejected the credentials
+func (e *HTTPStatusError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// ScalarmError is returned when a Scalarm service answers with a status other than expected, e.g. 'error'
+type ScalarmError struct {
+	Status string
+	Reason string
+}
+
+func (e *ScalarmError) Error() string {
+	if e.Reason == "" {
+		return "Something went wrong but without any details"
+	}
+	return e.Reason
+}
+
+// ScalarmClient executes requests against replicas of a Scalarm service: the healthiest ones are tried first
+// and every request is retried with the retry policy from the config. It never exits the process,
+// problems are reported with TransportError, HTTPStatusError and ScalarmError.
+type ScalarmClient struct {
+	HttpClient *http.Client
+	Endpoints  *EndpointPool
+	Config     *SimulationManagerConfig
+	Timeout    time.Duration
+	// name of the service used in error messages, e.g. "Experiment manager"
+	Service string
+}
+
+// Do executes the request and returns the first successful response, or the last one when all service urls
+// answered with a retryable status code; once ctx is done the request is neither retried nor sent to other urls
+func (c *ScalarmClient) Do(ctx context.Context, request ScalarmRequest) (*http.Response, error) {
+	authenticator, err := NewAuthenticator(c.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := NewRetryPolicy(c.Config)
+	var lastResponse *http.Response
+	var lastErr error
+
+	// 1. order service urls by their health
+	for _, serviceUrl := range c.Endpoints.Candidates() {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// 2. get next service url and prepare a request
+		requestUrl, err := BuildServiceURL(serviceUrl, request.ServiceMethod, c.Config.Development)
+		if err != nil {
+			fmt.Printf("[SiM] %v\n", err)
+			c.Endpoints.Failure(serviceUrl)
+			lastErr = err
+			continue
+		}
+
+		fmt.Printf("[SiM] %s\n", requestUrl)
+		req, err := request.newHTTPRequest(requestUrl)
+		if err == nil {
+			err = authenticator.Authenticate(req)
+		}
+		if err != nil {
+			if req != nil && req.Body != nil {
+				req.Body.Close()
+			}
+			if lastResponse != nil {
+				lastResponse.Body.Close()
+			}
+			return nil, err
+		}
+
+		req.Header.Set("Accept", "application/json")
+
+		// 3. execute request with the retry policy
+		requestStart := time.Now()
+		response, err := policy.DoContext(ctx, c.HttpClient, req, c.Timeout)
+		// 4. if there is no response or the service is unavailable go to 2.
+		if ctx.Err() != nil {
+			break
+		} else if err == nil && !policy.Retryable(response.StatusCode) {
+			c.Endpoints.Success(serviceUrl, time.Since(requestStart))
+			if lastResponse != nil {
+				lastResponse.Body.Close()
+			}
+			return response, nil
+		}
+
+		c.Endpoints.Failure(serviceUrl)
+
+		if err != nil {
+			lastErr = err
+		}
+
+		if response != nil {
+			if lastResponse != nil {
+				lastResponse.Body.Close()
+			}
+			lastResponse = response
+		}
+	}
+
+	if ctx.Err() != nil {
+		if lastResponse != nil {
+			lastResponse.Body.Close()
+		}
+		return nil, ctx.Err()
+	}
+
+	if lastResponse != nil {
+		return lastResponse, nil
+	}
+
+	return nil, &TransportError{Err: lastErr}
+}
+
+// Read executes the request and returns the response body, any status code other than 2xx is an HTTPStatusError
+func (c *ScalarmClient) Read(ctx context.Context, request ScalarmRequest) ([]byte, error) {
+	resp, err := c.send(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	} else if err != nil {
+		return nil, &TransportError{Err: err}
+	}
+
+	return body, nil
+}
+
+// ReadJSON executes the request and decodes the JSON response body into v
+func (c *ScalarmClient) ReadJSON(ctx context.Context, request ScalarmRequest, v interface{}) error {
+	body, err := c.Read(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		fmt.Printf("[SiM] Receiving: %s\n", body)
+		return errors.New("Returned response body is not JSON.")
+	}
+
+	return nil
+}
+
+// Download executes the request and copies the response body into w without buffering it in memory
+func (c *ScalarmClient) Download(ctx context.Context, request ScalarmRequest, w io.Writer) (int64, error) {
+	resp, err := c.send(ctx, request)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	written, err := io.Copy(w, resp.Body)
+	if ctx.Err() != nil {
+		return written, ctx.Err()
+	} else if err != nil {
+		return written, &TransportError{Err: err}
+	}
+
+	return written, nil
+}
+
+// send executes the request and returns its response only when the status code is 2xx
+func (c *ScalarmClient) send(ctx context.Context, request ScalarmRequest) (*http.Response, error) {
+	resp, err := c.Do(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, &HTTPStatusError{Service: c.Service, StatusCode: resp.StatusCode}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,157 @@
+package scalarmWorker
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func getScalarmClientMock(serverURL string, config *SimulationManagerConfig) *ScalarmClient {
+	return &ScalarmClient{
+		HttpClient: getHttpClientMock(serverURL),
+		Endpoints:  NewEndpointPool([]string{"em.scalarm.com"}),
+		Config:     config,
+		Timeout:    time.Second,
+		Service:    "Experiment manager",
+	}
+}
+
+func TestScalarmClientShouldReturnHTTPStatusErrorOnUnexpectedStatusCode(t *testing.T) {
+	// === GIVEN ===
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(401)
+	}))
+	defer server.Close()
+
+	client := getScalarmClientMock(server.URL, getSimConfig())
+
+	// === WHEN ===
+	_, err := client.Read(context.Background(), ScalarmRequest{Method: "GET", ServiceMethod: "experiments/random_experiment"})
+
+	// === THEN ===
+	statusErr, ok := err.(*HTTPStatusError)
+	if !ok || statusErr.StatusCode != 401 || !statusErr.Unauthorized() {
+		t.Fatalf("Got: '%v' - Expected HTTPStatusError with code 401", err)
+	}
+
+	if err.Error() != "Experiment manager response code: 401" {
+		t.Errorf("Got: '%v' - Expected 'Experiment manager response code: 401'", err.Error())
+	}
+}
+
+func TestScalarmClientShouldReturnTransportErrorWhenNoReplicaIsReachable(t *testing.T) {
+	// === GIVEN ===
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	config := getSimConfig()
+	config.RetryMaxAttempts = 1
+	client := getScalarmClientMock(server.URL, config)
+
+	// === WHEN ===
+	_, err := client.Read(context.Background(), ScalarmRequest{Method: "GET", ServiceMethod: "experiments"})
+
+	// === THEN ===
+	if transportErr, ok := err.(*TransportError); !ok || transportErr.Err == nil {
+		t.Errorf("Got: '%v' - Expected TransportError with the last problem", err)
+	}
+}
+
+func TestStatusResponseShouldReturnScalarmErrorForNotAcceptedStatus(t *testing.T) {
+	// === GIVEN ===
+	responses := []StatusResponse{
+		{Status: "ok"},
+		{Status: "preconditioned_failed"},
+		{Status: "error", Reason: "Simulation run not found"},
+		{Status: "error"},
+	}
+	expected := []error{
+		nil,
+		nil,
+		&ScalarmError{Status: "error", Reason: "Simulation run not found"},
+		errors.New("Something went wrong but without any details"),
+	}
+
+	for i, response := range responses {
+		// === WHEN ===
+		err := response.Err("ok", "preconditioned_failed")
+
+		// === THEN ===
+		if (err == nil) != (expected[i] == nil) || (err != nil && err.Error() != expected[i].Error()) {
+			t.Errorf("Got: '%v' - Expected '%v'", err, expected[i])
+		}
+		if _, ok := err.(*ScalarmError); err != nil && !ok {
+			t.Errorf("Got: '%T' - Expected *ScalarmError", err)
+		}
+	}
+}
+
+func TestScalarmClientShouldSendFullFileBodyOnRetriesAndToOtherReplicas(t *testing.T) {
+	// === GIVEN ===
+	received := map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received[r.Host] = append(received[r.Host], string(body))
+		if r.Host == "dead.com" {
+			w.WriteHeader(503)
+		}
+	}))
+	defer server.Close()
+
+	output, _ := ioutil.TempFile("", "output")
+	defer os.Remove(output.Name())
+	output.WriteString("binary output of the simulation run")
+	output.Close()
+
+	config := getSimConfig()
+	config.RetryMaxAttempts = 2
+	client := getScalarmClientMock(server.URL, config)
+	client.Endpoints = NewEndpointPool([]string{"dead.com", "alive.com"})
+	// the dead replica is tried first
+	client.Endpoints.Failure("alive.com")
+	// the retry backoff always fits in the timeout
+	client.Timeout = time.Minute
+
+	request := ScalarmRequest{Method: "PUT", ServiceMethod: "experiments/1/simulations/2", Body: FileBody{output.Name()},
+		ContentType: "application/octet-stream"}
+
+	// === WHEN ===
+	_, err := client.Read(context.Background(), request)
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	payload := "binary output of the simulation run"
+	expected := map[string][]string{"dead.com": {payload, payload}, "alive.com": {payload}}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("Got: '%v' - Expected '%v'", received, expected)
+	}
+}
+
+func TestScalarmClientShouldAcceptAnySuccessfulStatusCode(t *testing.T) {
+	for _, statusCode := range []int{200, 201, 204} {
+		// === GIVEN ===
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statusCode)
+		}))
+
+		client := getScalarmClientMock(server.URL, getSimConfig())
+
+		// === WHEN ===
+		_, err := client.Read(context.Background(), ScalarmRequest{Method: "PUT", ServiceMethod: "experiments/1/simulations/2"})
+		server.Close()
+
+		// === THEN ===
+		if err != nil {
+			t.Errorf("Got: '%v' - Expected nil for response code %v", err, statusCode)
+		}
+	}
+}
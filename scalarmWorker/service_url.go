@@ -0,0 +1,63 @@
+package scalarmWorker
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// parseServiceAddress parses address of a Scalarm service given as a host or as a full URL with a path prefix,
+// when the scheme is missing it is "http" in the development mode and "https" otherwise
+func parseServiceAddress(address string, development bool) (*url.URL, error) {
+	if !strings.Contains(address, "://") {
+		if development {
+			address = "http://" + address
+		} else {
+			address = "https://" + address
+		}
+	}
+
+	parsedURL, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("is not a valid URL: %v", err)
+	}
+
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, fmt.Errorf("has unsupported scheme '%s'", parsedURL.Scheme)
+	}
+
+	if parsedURL.Host == "" {
+		return nil, fmt.Errorf("has no host")
+	}
+
+	return parsedURL, nil
+}
+
+// BuildServiceURL returns the URL of a method of a Scalarm service: the method path is appended to the path prefix
+// of the service address and query strings of both are joined
+func BuildServiceURL(address, serviceMethod string, development bool) (string, error) {
+	serviceURL, err := parseServiceAddress(address, development)
+	if err != nil {
+		return "", fmt.Errorf("Address of Scalarm service '%s' %v", address, err)
+	}
+
+	methodPath, methodQuery := serviceMethod, ""
+	if i := strings.Index(serviceMethod, "?"); i >= 0 {
+		methodPath, methodQuery = serviceMethod[:i], serviceMethod[i+1:]
+	}
+
+	if methodPath != "" {
+		serviceURL.Path = strings.TrimSuffix(serviceURL.Path, "/") + "/" + strings.TrimPrefix(methodPath, "/")
+		serviceURL.RawPath = ""
+	}
+
+	if serviceURL.RawQuery != "" && methodQuery != "" {
+		serviceURL.RawQuery += "&" + methodQuery
+	} else if methodQuery != "" {
+		serviceURL.RawQuery = methodQuery
+	}
+
+	serviceURL.Fragment = ""
+
+	return serviceURL.String(), nil
+}
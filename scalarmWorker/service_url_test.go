@@ -0,0 +1,59 @@
+package scalarmWorker
+
+import (
+	"testing"
+)
+
+func TestBuildServiceURLShouldDefaultSchemeFromDevelopmentMode(t *testing.T) {
+	url, err := BuildServiceURL("em.scalarm.com", "experiments/1/next_simulation", false)
+	if err != nil || url != "https://em.scalarm.com/experiments/1/next_simulation" {
+		t.Errorf("Got: '%v', '%v' - Expected HTTPS URL", url, err)
+	}
+
+	url, err = BuildServiceURL("localhost:3000", "experiments/1/next_simulation", true)
+	if err != nil || url != "http://localhost:3000/experiments/1/next_simulation" {
+		t.Errorf("Got: '%v', '%v' - Expected HTTP URL", url, err)
+	}
+}
+
+func TestBuildServiceURLShouldKeepSchemePortAndPathPrefixOfAddress(t *testing.T) {
+	url, err := BuildServiceURL("http://storage.internal:8080/scalarm/", "experiments/1/simulations/2/stdout", false)
+	expected := "http://storage.internal:8080/scalarm/experiments/1/simulations/2/stdout"
+	if err != nil || url != expected {
+		t.Errorf("Got: '%v', '%v' - Expected '%v'", url, err, expected)
+	}
+
+	url, err = BuildServiceURL("https://scalarm.com/information", "/experiment_managers", true)
+	expected = "https://scalarm.com/information/experiment_managers"
+	if err != nil || url != expected {
+		t.Errorf("Got: '%v', '%v' - Expected '%v'", url, err, expected)
+	}
+}
+
+func TestBuildServiceURLShouldJoinQueryStrings(t *testing.T) {
+	url, err := BuildServiceURL("https://scalarm.com/em?token=abc", "experiments/random_experiment?limit=1", false)
+	expected := "https://scalarm.com/em/experiments/random_experiment?token=abc&limit=1"
+	if err != nil || url != expected {
+		t.Errorf("Got: '%v', '%v' - Expected '%v'", url, err, expected)
+	}
+
+	url, err = BuildServiceURL("scalarm.com/em", "experiments?limit=1", false)
+	expected = "https://scalarm.com/em/experiments?limit=1"
+	if err != nil || url != expected {
+		t.Errorf("Got: '%v', '%v' - Expected '%v'", url, err, expected)
+	}
+}
+
+func TestBuildServiceURLShouldRejectIncorrectAddress(t *testing.T) {
+	_, err := BuildServiceURL("ftp://scalarm.com", "experiments", false)
+	expectedMsg := "Address of Scalarm service 'ftp://scalarm.com' has unsupported scheme 'ftp'"
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expectedMsg)
+	}
+
+	_, err = BuildServiceURL("http:///information", "experiments", false)
+	expectedMsg = "Address of Scalarm service 'http:///information' has no host"
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expectedMsg)
+	}
+}
@@ -0,0 +1,122 @@
+package scalarmWorker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ExitCodeShutdown is the exit code of a worker stopped with SIGTERM or SIGINT
+const ExitCodeShutdown = 3
+
+// shutdown is shared by all slots of a running SimulationManager
+// to stop fetching new simulation runs when the worker receives a signal
+type shutdown struct {
+	requested chan struct{}
+	once      sync.Once
+	signal    syscall.Signal
+
+	executorsMutex sync.Mutex
+	// process groups of running executors, they are killed when the exit is forced
+	executors map[int]bool
+	exit      func(code int)
+}
+
+func newShutdown() *shutdown {
+	return &shutdown{requested: make(chan struct{}), executors: map[int]bool{}, exit: os.Exit}
+}
+
+// listen requests the shutdown when SIGTERM or SIGINT is received, the returned function stops listening.
+// A second signal received during the shutdown kills running executors and exits immediately.
+func (s *shutdown) listen() func() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	stopped := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case received := <-signals:
+				if !s.Requested() {
+					fmt.Printf("[SiM] Received %v signal, finishing work\n", received)
+					s.request(received.(syscall.Signal))
+					continue
+				}
+
+				fmt.Printf("[SiM] Received another %v signal, killing executors and exiting immediately\n", received)
+				s.forceExit()
+				return
+			case <-stopped:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(stopped)
+	}
+}
+
+func (s *shutdown) request(signal syscall.Signal) {
+	s.once.Do(func() {
+		s.signal = signal
+		close(s.requested)
+	})
+}
+
+// forceExit kills process groups of all running executors and exits with ExitCodeShutdown
+func (s *shutdown) forceExit() {
+	s.executorsMutex.Lock()
+	defer s.executorsMutex.Unlock()
+
+	for pid := range s.executors {
+		if err := SignalProcessGroup(pid, syscall.SIGKILL); err != nil {
+			fmt.Printf("[SiM] Could not kill the executor: %v\n", err)
+		}
+	}
+
+	s.exit(ExitCodeShutdown)
+}
+
+// addExecutor registers the process group of a started executor
+func (s *shutdown) addExecutor(pid int) {
+	s.executorsMutex.Lock()
+	defer s.executorsMutex.Unlock()
+
+	s.executors[pid] = true
+}
+
+// removeExecutor forgets the process group of an executor which has been reaped
+func (s *shutdown) removeExecutor(pid int) {
+	s.executorsMutex.Lock()
+	defer s.executorsMutex.Unlock()
+
+	delete(s.executors, pid)
+}
+
+// Requested returns true when the worker should not start any new simulation run
+func (s *shutdown) Requested() bool {
+	select {
+	case <-s.requested:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for the given time, it returns false when the shutdown has been requested or ctx is done in the meantime
+func (s *shutdown) sleep(ctx context.Context, duration time.Duration) bool {
+	select {
+	case <-s.requested:
+		return false
+	case <-ctx.Done():
+		return false
+	case <-time.After(duration):
+		return true
+	}
+}
@@ -0,0 +1,54 @@
+package scalarmWorker
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdownShouldKillExecutorsAndExitOnSecondSignal(t *testing.T) {
+	// === GIVEN ===
+	executorCmd := exec.Command("sleep", "30")
+	SetProcessGroup(executorCmd)
+	if err := executorCmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	executorErr := make(chan error, 1)
+	go func() { executorErr <- executorCmd.Wait() }()
+
+	exitCodes := make(chan int, 1)
+	s := newShutdown()
+	s.exit = func(code int) { exitCodes <- code }
+	s.addExecutor(executorCmd.Process.Pid)
+	stopListening := s.listen()
+	defer stopListening()
+
+	// === WHEN ===
+	syscall.Kill(os.Getpid(), syscall.SIGINT)
+	for start := time.Now(); !s.Requested() && time.Since(start) < 5*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGINT)
+
+	// === THEN ===
+	select {
+	case code := <-exitCodes:
+		if code != ExitCodeShutdown {
+			t.Errorf("Got: %v - Expected exit code %v", code, ExitCodeShutdown)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Worker has not exited after the second signal")
+	}
+
+	select {
+	case err := <-executorErr:
+		if status, ok := err.(*exec.ExitError); !ok || status.Sys().(syscall.WaitStatus).Signal() != syscall.SIGKILL {
+			t.Errorf("Got: '%v' - Expected executor killed with SIGKILL", err)
+		}
+	case <-time.After(5 * time.Second):
+		executorCmd.Process.Kill()
+		t.Errorf("Executor has not been killed after the second signal")
+	}
+}
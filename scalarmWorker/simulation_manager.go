@@ -2,22 +2,22 @@ package scalarmWorker
 
 import (
 	"archive/zip"
-	"bytes"
 	"container/list"
+	"context"
 	"encoding/json"
-	"flag"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"math/rand"
-	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
-	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -25,6 +25,9 @@ type SimulationManager struct {
 	Config      *SimulationManagerConfig
 	RootDirPath string
 	HttpClient  *http.Client
+
+	shutdown *shutdown
+	deadline time.Time
 }
 
 func listIncludeString(l *list.List, a string) bool {
@@ -36,106 +39,240 @@ func listIncludeString(l *list.List, a string) bool {
 	return false
 }
 
-// Calling Get multiple time until valid response or exceed 'communicationTimeout' period
-func getWithTimeout(client *http.Client, request *http.Request, communicationTimeout time.Duration) ([]byte, error) {
-	var resp *http.Response
-	var err error
-	communicationFailed := true
-	communicationStart := time.Now()
-	var body []byte
+// GetRandomExperimentID Makes request to experiments/random_experiment
+// Returns String: random experiment id available for current user
+func (sim SimulationManager) GetRandomExperimentID(experimentManagers *EndpointPool, client *http.Client) (string, error) {
+	return sim.GetRandomExperimentIDContext(context.Background(), experimentManagers, client)
+}
 
-	for communicationStart.Add(communicationTimeout).After(time.Now()) {
-		resp, err = client.Do(request)
+// GetRandomExperimentIDContext works as GetRandomExperimentID, the request is aborted when ctx is done
+func (sim SimulationManager) GetRandomExperimentIDContext(ctx context.Context, experimentManagers *EndpointPool,
+	client *http.Client) (string, error) {
 
-		if err != nil {
-			time.Sleep(1 * time.Second)
-			fmt.Printf("[SiM] %v\n", err)
-		} else {
-			communicationFailed = false
-			break
-		}
-	}
+	em := ExperimentManager{
+		HttpClient:           client,
+		Endpoints:            experimentManagers,
+		CommunicationTimeout: 30 * time.Second,
+		Config:               sim.Config}
 
-	if communicationFailed {
-		return nil, err
+	fmt.Printf("[SiM] Getting random experiment id...\n")
+	experimentID, err := em.GetRandomExperimentIDContext(ctx)
+	if err != nil {
+		return "", err
 	}
 
-	defer resp.Body.Close()
+	fmt.Printf("[SiM] Random experiment response body: %s\n", experimentID)
+	return experimentID, nil
+}
+
+// experimentRun groups everything the slots need to execute simulation runs of a single experiment
+type experimentRun struct {
+	ExperimentID         string
+	ExperimentDir        string
+	CodeBaseDir          string
+	ExperimentManager    *ExperimentManager
+	StorageManager       *StorageManager
+	Outbox               *Outbox
+	ExperimentManagers   *EndpointPool
+	StorageManagers      *EndpointPool
+	CommunicationTimeout time.Duration
+	SimulationsLimit     int
+	MaxFailures          int
+
+	mutex               sync.Mutex
+	simulationsStarted  int
+	simulationsDone     int
+	consecutiveFailures int
+	timeConstraint      time.Duration
+	durationsSum        time.Duration
+	durationsCount      int
+	err                 error
+}
 
-	if body, err = ioutil.ReadAll(resp.Body); err != nil {
-		return nil, err
+// fail remembers the first error which stops all slots, e.g. when results could not be reported
+func (run *experimentRun) fail(err error) {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	if run.err == nil {
+		run.err = err
 	}
+}
 
-	return body, nil
+// failed returns the error which stopped the slots or nil
+func (run *experimentRun) failed() error {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	return run.err
 }
 
-func (sim SimulationManager) ExecuteScalarmRequest(reqInfo RequestInfo, serviceUrls []string, client *http.Client, timeout time.Duration) []byte {
-	protocol := "https"
-	if sim.Config.Development {
-		protocol = "http"
+// reserveSimulation returns false when the simulations limit does not allow to start another run
+func (run *experimentRun) reserveSimulation() bool {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	if run.SimulationsLimit > 0 && run.simulationsStarted >= run.SimulationsLimit {
+		return false
 	}
 
-	// 1. shuffle service url
-	perm := rand.Perm(len(serviceUrls))
+	run.simulationsStarted++
+	return true
+}
 
-	for _, v := range perm {
-		// 2. get next service url and prepare a request
-		serviceURL := serviceUrls[v]
-		fmt.Printf("[SiM] %s://%s/%s\n", protocol, serviceURL, reqInfo.ServiceMethod)
-		req, err := http.NewRequest(reqInfo.HttpMethod, fmt.Sprintf("%s://%s/%s", protocol, serviceURL, reqInfo.ServiceMethod), reqInfo.Body)
-		if err != nil {
-			Fatal(err)
-		}
-		req.SetBasicAuth(sim.Config.ExperimentManagerUser, sim.Config.ExperimentManagerPass)
-		if reqInfo.Body != nil {
-			req.Header.Set("Content-Type", reqInfo.ContentType)
-		}
-		// 3. execute request with timeout
-		response, err := getWithTimeout(client, req, timeout)
-		// 4. if response body is nil go to 2.
-		if err == nil {
-			return response
-		}
+// releaseSimulation gives back a reservation which did not end up with a simulation run
+func (run *experimentRun) releaseSimulation() {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	run.simulationsStarted--
+}
+
+// finishSimulation returns the number of simulation runs done so far
+func (run *experimentRun) finishSimulation() int {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	run.simulationsDone++
+	return run.simulationsDone
+}
+
+// recordFailure counts consecutive failed simulation runs of all slots, a successful run resets the counter
+func (run *experimentRun) recordFailure(failed bool) int {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	if failed {
+		run.consecutiveFailures++
+	} else {
+		run.consecutiveFailures = 0
 	}
 
-	Fatal(fmt.Errorf("Could not execute request against Scalarm service"))
-	return nil
+	return run.consecutiveFailures
 }
 
-// GetRandomExperimentID Makes request to experiments/random_experiment
-// Returns String: random experiment id available for current user
-func (sim SimulationManager) GetRandomExperimentID(experimentManagers []string, client *http.Client) string {
-	communicationTimeout := 30 * time.Second
-	fmt.Printf("[SiM] Getting random experiment id...\n")
-	getExpReqInfo := RequestInfo{"GET", nil, "", "experiments/random_experiment"}
-	body := sim.ExecuteScalarmRequest(getExpReqInfo, experimentManagers, client, communicationTimeout)
-	fmt.Printf("[SiM] Random experiment response body: %s\n", body)
-	return fmt.Sprintf("%s", body)
+// failuresLimitReached returns true when the worker should give up due to too many consecutive failures
+func (run *experimentRun) failuresLimitReached() bool {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	return run.MaxFailures > 0 && run.consecutiveFailures >= run.MaxFailures
 }
 
-func (sim SimulationManager) Run() {
-	// TODO: use flags with options to override all sim.Config values
-	simulationsLimitPtr := flag.Int("simulations_limit", -1, "max number of simulation run to execute")
-	flag.Parse()
+// recordDuration remembers how long a simulation run took and what was its time constraint
+func (run *experimentRun) recordDuration(duration, timeConstraint time.Duration) {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	run.durationsSum += duration
+	run.durationsCount++
+	if timeConstraint > 0 {
+		run.timeConstraint = timeConstraint
+	}
+}
 
-	simulationsLimit := *simulationsLimitPtr
+// expectedDuration returns the average duration of simulation runs executed so far
+// or the last known time constraint when no run has finished yet
+func (run *experimentRun) expectedDuration() time.Duration {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	if run.durationsCount > 0 {
+		return run.durationsSum / time.Duration(run.durationsCount)
+	}
+
+	return run.timeConstraint
+}
+
+// rememberTimeConstraint keeps the time constraint of a simulation run which was not executed,
+// so other slots do not fetch runs before any run has finished
+func (run *experimentRun) rememberTimeConstraint(timeConstraint time.Duration) {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	if timeConstraint > 0 {
+		run.timeConstraint = timeConstraint
+	}
+}
 
-	// use sim.Config json if simulations limit not provided in command line
-	if simulationsLimit == -1 {
-		simulationsLimit = sim.Config.SimulationsLimit
+// canStartBefore returns true when a new simulation run is expected to finish before the deadline
+func (run *experimentRun) canStartBefore(deadline time.Time, now time.Time) bool {
+	if deadline.IsZero() {
+		return true
 	}
 
+	return deadline.Sub(now) > run.expectedDuration()
+}
+
+func (run *experimentRun) limitReached() bool {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+
+	return run.SimulationsLimit > 0 && run.simulationsDone >= run.SimulationsLimit
+}
+
+// ErrSimulationsLimitReached is returned by Run when the configured number of simulation runs has been executed
+var ErrSimulationsLimitReached = errors.New("Simulations limit reached")
+
+// ShutdownError is returned by Run when the worker has been stopped with SIGTERM or SIGINT
+type ShutdownError struct {
+	Signal syscall.Signal
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("Stopped by %v signal", e.Signal)
+}
+
+// Run executes simulation runs of the configured experiment or of random user's experiments,
+// it returns nil when there is nothing more to do and an error when the worker has to stop,
+// the process is never exited
+func (sim SimulationManager) Run() error {
+	return sim.RunContext(context.Background())
+}
+
+// RunContext works as Run until ctx is done: then no new simulation run is started, requests to Scalarm services
+// and their retries are aborted, processes of running simulation runs are killed and ctx.Err() is returned
+func (sim SimulationManager) RunContext(ctx context.Context) error {
+	sim.shutdown = newShutdown()
+	stopListening := sim.shutdown.listen()
+	defer stopListening()
+
+	// configs created in code, e.g. by tests, are not read with CreateSimulationManagerConfig
+	sim.Config.setDefaults()
+
+	simulationsLimit := sim.Config.SimulationsLimit
+
 	if simulationsLimit > 0 {
 		fmt.Printf("[SiM] Simulations limit set to %v\n", simulationsLimit)
 	}
 
-	if sim.Config.Timeout <= 0 {
-		sim.Config.Timeout = 60
+	if sim.Config.ParallelSlots > 1 {
+		fmt.Printf("[SiM] Parallel slots set to %v\n", sim.Config.ParallelSlots)
 	}
+
 	communicationTimeout := time.Duration(sim.Config.Timeout) * time.Second
 
-	if sim.Config.CooldownInterval <= 0 {
-		sim.Config.CooldownInterval = 5
+	// default paths depend on the working directory of the simulation manager
+	if sim.Config.InformationServiceCachePath == "" {
+		sim.Config.InformationServiceCachePath = path.Join(sim.RootDirPath, ".information_service_cache.json")
+	}
+
+	if sim.Config.OutboxDir == "" {
+		sim.Config.OutboxDir = path.Join(sim.RootDirPath, "outbox")
+	}
+
+	if sim.Config.Deadline != "" {
+		deadline, err := ParseDeadline(sim.Config.Deadline, time.Now())
+		if err != nil {
+			return err
+		}
+		sim.deadline = deadline
+	} else if deadline, ok := DetectDeadline(os.Getenv, time.Now()); ok {
+		sim.deadline = deadline
+	}
+
+	if !sim.deadline.IsZero() {
+		fmt.Printf("[SiM] No simulation run will be started if it is not expected to finish before %v\n", sim.deadline.Format(time.RFC3339))
 	}
 
 	if len(sim.Config.StartAt) > 0 {
@@ -144,7 +281,9 @@ func (sim SimulationManager) Run() {
 			fmt.Printf("[SiM] %v\n", err)
 		} else {
 			fmt.Println("[SiM] We have start_at provided")
-			time.Sleep(startTime.Sub(time.Now()))
+			if err := sleepContext(ctx, startTime.Sub(time.Now())); err != nil {
+				return err
+			}
 			fmt.Println("[SiM] We are ready to work")
 		}
 	}
@@ -154,21 +293,62 @@ func (sim SimulationManager) Run() {
 		HttpClient:           sim.HttpClient,
 		BaseUrl:              sim.Config.InformationServiceUrl,
 		CommunicationTimeout: communicationTimeout,
-		Config:               sim.Config}
+		Config:               sim.Config,
+		CachePath:            sim.Config.InformationServiceCachePath,
+		CacheMaxAge:          time.Duration(sim.Config.InformationServiceCacheMaxAge) * time.Second}
 
-	var experimentManagers []string
-	experimentManagers, err := is.GetExperimentManagers()
+	experimentManagerUrls, err := is.GetExperimentManagersContext(ctx)
 	if err != nil {
-		Fatal(err)
+		return err
 	}
 
 	// getting storage manager address
-	var storageManagers []string
-	storageManagers, err = is.GetStorageManagers()
+	storageManagerUrls, err := is.GetStorageManagersContext(ctx)
 	if err != nil {
-		Fatal(err)
+		return err
 	}
 
+	// health of replicas is shared by all experiments and slots
+	experimentManagers := NewEndpointPool(experimentManagerUrls)
+	storageManagers := NewEndpointPool(storageManagerUrls)
+
+	sm := &StorageManager{
+		HttpClient:           sim.HttpClient,
+		Endpoints:            storageManagers,
+		CommunicationTimeout: communicationTimeout,
+		Config:               sim.Config}
+
+	// new replicas are used and removed ones abandoned while the worker is running
+	if sim.Config.UsesInformationService() {
+		stopRefreshing := is.RefreshManagersContext(ctx, time.Duration(sim.Config.ManagersRefreshInterval)*time.Second, experimentManagers, storageManagers)
+		defer stopRefreshing()
+	} else {
+		fmt.Println("[SiM] Using Experiment and Storage Managers from the config, Information Service is not used")
+	}
+
+	// results left by the previous run of the worker are sent before any new simulation run is fetched
+	sender := &outboxSender{
+		HttpClient:           sim.HttpClient,
+		ExperimentManagers:   experimentManagers,
+		StorageManager:       sm,
+		CommunicationTimeout: communicationTimeout,
+		Config:               sim.Config}
+
+	outbox, err := OpenOutbox(sim.Config.OutboxDir, sender.send)
+	if err != nil {
+		return err
+	}
+	outbox.MaxAttempts = sim.Config.OutboxMaxAttempts
+	outbox.MaxAge = time.Duration(sim.Config.OutboxMaxAge) * time.Second
+
+	if err := sim.drainOutbox(ctx, outbox); err != nil {
+		return err
+	}
+
+	stopSender := outbox.RunSender(ctx, NewRetryPolicy(sim.Config))
+	defer stopSender()
+	defer sim.flushOutboxOnExit(ctx, outbox)
+
 	var experimentID string
 	executedExperiments := list.New()
 	singleExperiment := false
@@ -177,24 +357,32 @@ func (sim SimulationManager) Run() {
 		// get experiment_id from EM if not present in SiM sim.Config
 		if sim.Config.ExperimentId == "" {
 			experimentID = ""
-			for experimentID == "" {
-				experimentID = sim.GetRandomExperimentID(experimentManagers, sim.HttpClient)
+			for experimentID == "" && !sim.shutdown.Requested() && ctx.Err() == nil {
+				experimentID, err = sim.GetRandomExperimentIDContext(ctx, experimentManagers, sim.HttpClient)
 
-				if experimentID == "" {
+				if err != nil {
+					return err
+				} else if experimentID == "" {
 					fmt.Printf("[SiM] Random experiment id empty, waiting 30 seconds to try again\n")
-					time.Sleep(30 * time.Second)
+					sim.shutdown.sleep(ctx, 30*time.Second)
 
 					// check if this experiment was executed by this SiM
 				} else if listIncludeString(executedExperiments, experimentID) {
 					fmt.Printf("[SiM] That experiment was already executed, waiting 10 seconds to get other id\n")
 					experimentID = ""
-					time.Sleep(10 * time.Second)
+					sim.shutdown.sleep(ctx, 10*time.Second)
 
 					// its new experiment - add it to executed list
 				} else {
 					executedExperiments.PushBack(experimentID)
 				}
 			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			} else if sim.shutdown.Requested() {
+				return sim.stoppedByShutdown()
+			}
 		} else {
 			experimentID = sim.Config.ExperimentId
 			singleExperiment = true
@@ -205,28 +393,30 @@ func (sim SimulationManager) Run() {
 
 		em := ExperimentManager{
 			HttpClient:           sim.HttpClient,
-			BaseUrls:             experimentManagers,
+			Endpoints:            experimentManagers,
 			CommunicationTimeout: communicationTimeout,
 			Config:               sim.Config,
 			ExperimentId:         experimentID}
 
 		if err = os.MkdirAll(experimentDir, 0777); err != nil {
-			Fatal(err)
+			return err
 		}
 
-		// 3. get code base for the experiment if necessary
+		// 3. get code base for the experiment if necessary - it is shared by all slots
 		codeBaseDir := path.Join(experimentDir, "code_base")
 
 		if _, err := os.Stat(codeBaseDir); os.IsNotExist(err) {
 			if err = os.MkdirAll(codeBaseDir, 0777); err != nil {
-				Fatal(err)
+				return err
 			}
 
 			for i := 0; i < 10; i++ {
 				fmt.Println("[SiM] Getting code base ...")
 
-				err = em.DownloadExperimentCodeBase(codeBaseDir)
-				if err != nil {
+				err = em.DownloadExperimentCodeBaseContext(ctx, codeBaseDir)
+				if ctx.Err() != nil {
+					return ctx.Err()
+				} else if err != nil {
 					fmt.Printf("[SiM] There was a problem while getting code base: %v\n", err)
 				} else {
 
@@ -245,313 +435,544 @@ func (sim SimulationManager) Run() {
 
 				if err == nil {
 					break
-				} else {
-					time.Sleep(time.Duration(sim.Config.CooldownInterval) * time.Second)
+				} else if err := sleepContext(ctx, time.Duration(sim.Config.CooldownInterval)*time.Second); err != nil {
+					return err
 				}
 			}
 
 			if err = exec.Command("sh", "-c", fmt.Sprintf("chmod a+x \"%s\"/*", codeBaseDir)).Run(); err != nil {
 				fmt.Println("[SiM] An error occurred during executing 'chmod' command. Please check if you have required permissions.")
-				fmt.Printf("[Fatal error] occured during '%v' execution \n", fmt.Sprintf("chmod a+x \"%s\"/*", codeBaseDir))
-				fmt.Printf("[Fatal error] %s\n", err.Error())
-				os.Exit(2)
+				return fmt.Errorf("'chmod a+x \"%s\"/*' failed: %v", codeBaseDir, err)
 			}
 		}
 
-		// 4. main loop for getting simulation runs of an experiment
-		simulationsDone := 0
-		for {
-			nextSimulationFailed := true
-			communicationStart := time.Now()
+		// 4. execute simulation runs of the experiment in all slots
+		run := &experimentRun{
+			ExperimentID:         experimentID,
+			ExperimentDir:        experimentDir,
+			CodeBaseDir:          codeBaseDir,
+			ExperimentManager:    &em,
+			StorageManager:       sm,
+			Outbox:               outbox,
+			ExperimentManagers:   experimentManagers,
+			StorageManagers:      storageManagers,
+			CommunicationTimeout: communicationTimeout,
+			SimulationsLimit:     simulationsLimit,
+			MaxFailures:          sim.Config.MaxConsecutiveFailures,
+		}
 
-			var simulationRun map[string]interface{}
-			wait := false
+		var slots sync.WaitGroup
+		for slot := 1; slot <= sim.Config.ParallelSlots; slot++ {
+			slots.Add(1)
+			go func(slot int) {
+				defer slots.Done()
+				sim.runSlot(ctx, slot, run)
+			}(slot)
+		}
+		slots.Wait()
 
-			// 4.a getting input values for next simulation run
-			for communicationStart.Add(communicationTimeout * time.Duration(len(experimentManagers))).After(time.Now()) {
-				fmt.Println("[SiM] Getting next simulation run ...")
-				simulationRun, err = em.GetNextSimulationRunConfig()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 
-				if err != nil {
-					Fatal(err)
-				}
+		if err := run.failed(); err != nil {
+			return err
+		}
 
-				status := simulationRun["status"].(string)
+		if sim.shutdown.Requested() {
+			return sim.stoppedByShutdown()
+		}
 
-				if status == "all_sent" {
-					fmt.Println("[SiM] There is no more simulations to run in this experiment.")
-				} else if status == "error" {
-					fmt.Println("[SiM] An error occurred while getting next simulation.")
-				} else if status == "wait" {
-					fmt.Printf("[SiM] There is no more simulations to run in this experiment "+
-						"at the moment, time to wait: %vs\n", simulationRun["duration_in_seconds"])
-					wait = true
-					break
-				} else if status != "ok" {
-					fmt.Println("[SiM] We cannot continue due to unsupported status.")
-				} else {
-					nextSimulationFailed = false
-					break
-				}
+		if !run.canStartBefore(sim.deadline, time.Now()) {
+			fmt.Println("[SiM] There is not enough time left before the deadline -> finishing work.")
+			return nil
+		}
 
-				fmt.Println("[SiM] There was a problem while getting next simulation to run.")
-				time.Sleep(time.Duration(sim.Config.CooldownInterval) * time.Second)
-			}
-			if wait {
-				time.Sleep(time.Duration(simulationRun["duration_in_seconds"].(float64)) * time.Second)
-				continue
-			}
+		if run.failuresLimitReached() {
+			return fmt.Errorf("%v consecutive simulation runs failed, giving up", run.MaxFailures)
+		}
 
-			if nextSimulationFailed {
-				fmt.Println("[SiM] Couldn't get simulation to run")
-				if singleExperiment {
-					fmt.Println("[SiM] that was single experiment run -> finishing work.")
-					return
-				} else {
-					fmt.Println("[SiM] will try another experiment")
-					break
-				}
-			}
+		if run.limitReached() {
+			fmt.Printf("[SiM] Exiting due to simulation runs limit (%v)\n", simulationsLimit)
+			return ErrSimulationsLimitReached
+		}
 
-			simulationIndex := int(simulationRun["simulation_id"].(float64))
+		fmt.Println("[SiM] Couldn't get simulation to run")
+		if singleExperiment {
+			fmt.Println("[SiM] that was single experiment run -> finishing work.")
+			return nil
+		}
+		fmt.Println("[SiM] will try another experiment")
+	}
+}
 
-			fmt.Printf("[SiM] Simulation index: %v\n", simulationIndex)
-			fmt.Printf("[SiM] Simulation execution constraints: %v\n", simulationRun["execution_constraints"])
+// drainOutbox sends results left in the outbox, it waits for Scalarm services at most outbox_drain_timeout,
+// results which are still not sent are left for the background sender
+func (sim SimulationManager) drainOutbox(ctx context.Context, outbox *Outbox) error {
+	policy := NewRetryPolicy(sim.Config)
+	drainCtx, cancel := context.WithTimeout(ctx, time.Duration(sim.Config.OutboxDrainTimeout)*time.Second)
+	defer cancel()
 
-			simulationDirPath := path.Join(experimentDir, fmt.Sprintf("simulation_%v", simulationIndex))
+	for failures := 1; outbox.Len() > 0; failures++ {
+		fmt.Printf("[SiM] Sending %v results left in outbox ...\n", outbox.Len())
 
-			err = os.MkdirAll(simulationDirPath, 0777)
-			if err != nil {
-				Fatal(err)
+		err := outbox.flush(drainCtx, "")
+		if err == nil {
+			break
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		} else if drainCtx.Err() != nil {
+			break
+		}
+
+		wait := policy.Backoff(failures)
+		fmt.Printf("[SiM] Could not send results from outbox: %v, retrying in %v\n", err, wait)
+		if !sim.shutdown.sleep(drainCtx, wait) {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			} else if drainCtx.Err() != nil {
+				break
 			}
+			return sim.stoppedByShutdown()
+		}
+	}
 
-			inputParameters, _ := json.Marshal(simulationRun["input_parameters"].(map[string]interface{}))
+	if outbox.Len() > 0 {
+		fmt.Printf("[SiM] %v results left in outbox are sent in the background\n", outbox.Len())
+		outbox.retryLater(ctx, errors.New("Results left in outbox"))
+	}
 
-			err = ioutil.WriteFile(path.Join(simulationDirPath, "input.json"), inputParameters, 0777)
-			if err != nil {
-				Fatal(err)
-			}
+	return nil
+}
 
-			simulationDir, err := os.Open(simulationDirPath)
-			if err != nil {
-				Fatal(err)
-			}
+// flushOutboxOnExit makes the last attempt to send results before the worker stops, bounded by outbox_drain_timeout,
+// the ones which are not sent are kept in the outbox until the worker starts again
+func (sim SimulationManager) flushOutboxOnExit(ctx context.Context, outbox *Outbox) {
+	if ctx.Err() != nil || outbox.Len() == 0 {
+		return
+	}
 
-			wd, err := os.Getwd()
-			fmt.Printf("[SiM] Working dir: %v\n", wd)
-			if err = simulationDir.Chdir(); err != nil {
-				Fatal(err)
-			}
+	flushCtx, cancel := context.WithTimeout(ctx, time.Duration(sim.Config.OutboxDrainTimeout)*time.Second)
+	defer cancel()
 
-			// 4b. run an adapter script (input writer) for input information: input.json -> some specific code
-			if _, err := os.Stat(path.Join(codeBaseDir, "input_writer")); err == nil {
-				fmt.Println("[SiM] Before input writer ...")
-				inputWriterCmd := exec.Command("sh", "-c", path.Join(codeBaseDir, "input_writer input.json >>_stdout.txt 2>&1"))
-				inputWriterCmd.Dir = simulationDirPath
-				if err = inputWriterCmd.Run(); err != nil {
-					fmt.Println("[SiM] An error occurred during 'input_writer' execution.")
-					fmt.Println("[SiM] Please check if 'input_writer' executes correctly on the selected infrastructure.")
-					fmt.Printf("[Fatal error] occured during '%v' execution \n", strings.Join(inputWriterCmd.Args, " "))
-					fmt.Printf("[Fatal error] %s\n", err.Error())
-					PrintStdoutLog()
-					os.Exit(1)
-				}
-				fmt.Println("[SiM] After input writer ...")
-			}
+	if err := outbox.flush(flushCtx, ""); err != nil {
+		fmt.Printf("[SiM] %v results are kept in outbox %s until the worker starts again: %v\n",
+			outbox.Len(), outbox.Dir, err)
+	}
+}
+
+func (sim SimulationManager) stoppedByShutdown() error {
+	fmt.Printf("[SiM] Exiting due to %v signal\n", sim.shutdown.signal)
+	return &ShutdownError{Signal: sim.shutdown.signal}
+}
+
+// runSlot executes simulation runs of the experiment one after another until there is nothing more to do
+func (sim SimulationManager) runSlot(ctx context.Context, slot int, run *experimentRun) {
+	for {
+		if ctx.Err() != nil || run.failed() != nil || sim.shutdown.Requested() || run.failuresLimitReached() || !run.canStartBefore(sim.deadline, time.Now()) ||
+			!run.reserveSimulation() {
+			return
+		}
 
-			// 4c.1. progress monitoring scheduling if available
-			messages := make(chan struct{}, 1)
-			finished := make(chan struct{}, 1)
-			go sim.IntermediateMonitoring(messages, finished, codeBaseDir, experimentManagers, simulationIndex, simulationDirPath, sim.HttpClient, experimentID)
-
-			// 4c. run an executor of this simulation
-			fmt.Println("[SiM] Before executor ...")
-			executorCmd := exec.Command("sh", "-c", path.Join(codeBaseDir, "executor >>_stdout.txt 2>&1"))
-			executorCmd.Dir = simulationDirPath
-			if err = executorCmd.Start(); err != nil {
-				fmt.Println("[SiM] An error occurred during 'executor' execution.")
-				fmt.Println("[SiM] Please check if 'executor' executes correctly on the selected infrastructure.")
-				fmt.Printf("[Fatal error] occured during '%v' execution \n", strings.Join(executorCmd.Args, " "))
-				fmt.Printf("[Fatal error] %s\n", err.Error())
-				PrintStdoutLog()
-				os.Exit(1)
+		simulationRun, wait, err := sim.getNextSimulationRun(ctx, run)
+
+		if err != nil {
+			run.releaseSimulation()
+			if ctx.Err() == nil {
+				run.fail(err)
 			}
+			return
+		}
+
+		if wait {
+			run.releaseSimulation()
+			sim.shutdown.sleep(ctx, time.Duration(simulationRun.DurationInSeconds*float64(time.Second)))
+			continue
+		}
 
-			pid := executorCmd.Process.Pid
-			RunProcessMonitoring(pid, &sim, &em, simulationIndex)
+		if simulationRun == nil {
+			run.releaseSimulation()
+			return
+		}
 
-			if err = executorCmd.Wait(); err != nil {
-				fmt.Println("[SiM] An error occurred during 'executor' execution.")
-				fmt.Println("[SiM] Please check if 'executor' executes correctly on the selected infrastructure.")
-				fmt.Printf("[Fatal error] occured during '%v' execution \n", strings.Join(executorCmd.Args, " "))
-				fmt.Printf("[Fatal error] %s\n", err.Error())
-				PrintStdoutLog()
-				os.Exit(1)
+		// the fetched run is not started when its time constraint does not fit before the deadline,
+		// it is given back so that Scalarm sends it to another worker right away
+		if !simulationRun.CanFinishBefore(sim.deadline, time.Now()) {
+			fmt.Printf("[SiM] Simulation run %v with time constraint %v cannot finish before the deadline -> finishing work.\n",
+				simulationRun.SimulationID, simulationRun.TimeConstraint())
+			if err := run.ExperimentManager.ResetSimulationRunContext(ctx, simulationRun.SimulationID); err != nil {
+				fmt.Printf("[SiM] Simulation run %v could not be given back, it is sent again after its time constraint: %v\n",
+					simulationRun.SimulationID, err)
 			}
+			run.rememberTimeConstraint(simulationRun.TimeConstraint())
+			run.releaseSimulation()
+			return
+		}
 
-			fmt.Println("[SiM] After executor ...")
-
-			messages <- struct{}{}
-			close(messages)
-
-			// 4d. run an adapter script (output reader) to transform specific output format to scalarm model (output.json)
-			if _, err := os.Stat(path.Join(codeBaseDir, "output_reader")); err == nil {
-				fmt.Println("[SiM] Before output reader ...")
-				outputReaderCmd := exec.Command("sh", "-c", path.Join(codeBaseDir, "output_reader >>_stdout.txt 2>&1"))
-				outputReaderCmd.Dir = simulationDirPath
-				if err = outputReaderCmd.Run(); err != nil {
-					fmt.Println("[SiM] An error occurred during 'output_reader' execution.")
-					fmt.Println("[SiM] Please check if 'output_reader' executes correctly on the selected infrastructure.")
-					fmt.Printf("[Fatal error] occured during '%v' execution \n", strings.Join(outputReaderCmd.Args, " "))
-					fmt.Printf("[Fatal error] %s\n", err.Error())
-					PrintStdoutLog()
-					os.Exit(1)
-				}
-				fmt.Println("[SiM] After output reader ...")
+		simulationStart := time.Now()
+		succeeded, err := sim.executeSimulationRun(ctx, slot, run, simulationRun)
+		if err != nil {
+			if ctx.Err() == nil {
+				run.fail(err)
 			}
+			return
+		}
+		run.recordDuration(time.Since(simulationStart), simulationRun.TimeConstraint())
 
-			// 4e. upload output json to experiment manager and set the run simulation as done
-			simulationRunResults := new(SimulationRunResults)
+		simulationsDone := run.finishSimulation()
 
-			if _, err := os.Stat("output.json"); os.IsNotExist(err) {
-				simulationRunResults.Status = "error"
-				simulationRunResults.Reason = fmt.Sprintf("No output.json file found: %s", err.Error())
-			} else {
-				file, err := os.Open("output.json")
+		if failures := run.recordFailure(!succeeded); failures > 0 {
+			fmt.Printf("[SiM] Consecutive failed simulation runs: %v/%v\n", failures, run.MaxFailures)
+		}
 
-				if err != nil {
-					simulationRunResults.Status = "error"
-					simulationRunResults.Reason = fmt.Sprintf("Could not open output.json: %s", err.Error())
-				} else {
-					err = json.NewDecoder(file).Decode(&simulationRunResults)
+		if run.SimulationsLimit > 0 {
+			fmt.Printf("[SiM] Simulations done: %v/%v\n", simulationsDone, run.SimulationsLimit)
+		}
+	}
+}
 
-					if err != nil {
-						simulationRunResults.Status = "error"
-						simulationRunResults.Reason = fmt.Sprintf("Error during output.json parsing: %s", err.Error())
-					}
-				}
+// getNextSimulationRun returns nil when there is no simulation run to execute
+// and wait set to true when the Experiment Manager asked to come back later
+func (sim SimulationManager) getNextSimulationRun(ctx context.Context, run *experimentRun) (*SimulationRunConfig, bool, error) {
+	communicationStart := time.Now()
 
-				file.Close()
-			}
+	// 4.a getting input values for next simulation run
+	for communicationStart.Add(run.CommunicationTimeout*time.Duration(run.ExperimentManagers.Len())).After(time.Now()) &&
+		!sim.shutdown.Requested() && ctx.Err() == nil {
+		fmt.Println("[SiM] Getting next simulation run ...")
+		simulationRun, err := run.ExperimentManager.GetNextSimulationRunConfigContext(ctx)
 
-			resultJson, _ := json.Marshal(simulationRunResults.Results)
+		if err != nil {
+			return nil, false, err
+		}
 
-			if !simulationRunResults.isValid() || !IsJSON(string(resultJson)) {
-				fmt.Printf("[output.json] Invalid results.json: %s\n", resultJson)
-				simulationRunResults.Status = "error"
-				simulationRunResults.Results = nil
-				simulationRunResults.Reason = fmt.Sprintf("Invalid results.json: %s", resultJson)
-				resultJson = nil
-			}
+		status := simulationRun.Status
+
+		if status == "all_sent" {
+			fmt.Println("[SiM] There is no more simulations to run in this experiment.")
+		} else if status == "error" {
+			fmt.Println("[SiM] An error occurred while getting next simulation.")
+		} else if status == "wait" {
+			fmt.Printf("[SiM] There is no more simulations to run in this experiment "+
+				"at the moment, time to wait: %vs\n", simulationRun.DurationInSeconds)
+			return simulationRun, true, nil
+		} else if status != "ok" {
+			fmt.Println("[SiM] We cannot continue due to unsupported status.")
+		} else {
+			return simulationRun, false, nil
+		}
 
-			// 4f. upload structural results of a simulation run
-			data := url.Values{}
-			data.Set("status", simulationRunResults.Status)
-			data.Add("reason", simulationRunResults.Reason)
-			data.Add("result", string(resultJson))
+		fmt.Println("[SiM] There was a problem while getting next simulation to run.")
+		sim.shutdown.sleep(ctx, time.Duration(sim.Config.CooldownInterval)*time.Second)
+	}
 
-			fmt.Printf("[SiM] Results: %v\n", data)
+	return nil, false, nil
+}
 
-			_, err = em.MarkSimulationRunAsComplete(simulationIndex, data)
-			if err != nil {
-				fmt.Println("[SiM] Error during marking simulation run as complete.")
-				Fatal(err)
-			}
+// executeSimulationRun runs all adapters of a single simulation run in its own directory and reports results
+// through the outbox, it returns false when one of the adapters failed and an error when the results could not be saved
+func (sim SimulationManager) executeSimulationRun(ctx context.Context, slot int, run *experimentRun,
+	simulationRun *SimulationRunConfig) (bool, error) {
 
-			// 4g. upload binary output if provided
-			if _, err := os.Stat("output.tar.gz"); err == nil {
-				fmt.Printf("[SiM] Uploading 'output.tar.gz' ...\n")
-				file, err := os.Open("output.tar.gz")
+	reporter := &outboxReporter{run.ExperimentManager, run.Outbox}
+	simulationIndex := simulationRun.SimulationID
 
-				if err != nil {
-					Fatal(err)
-				}
+	fmt.Printf("[SiM] Simulation index: %v (slot %v)\n", simulationIndex, slot)
+	fmt.Printf("[SiM] Simulation execution constraints: %+v\n", simulationRun.ExecutionConstraints)
 
-				defer file.Close()
+	simulationDirPath := path.Join(run.ExperimentDir, fmt.Sprintf("simulation_%v", simulationIndex))
+	stdoutPath := path.Join(simulationDirPath, "_stdout.txt")
 
-				requestBody := &bytes.Buffer{}
-				writer := multipart.NewWriter(requestBody)
-				part, err := writer.CreateFormFile("file", filepath.Base("output.tar.gz"))
-				if err != nil {
-					Fatal(err)
-				}
-				_, err = io.Copy(part, file)
+	simulationRunResults, adapterErr := sim.processSimulationRun(ctx, reporter, run.CodeBaseDir, simulationDirPath, simulationRun)
+	if ctx.Err() != nil {
+		fmt.Printf("[SiM] Simulation run %v has been canceled, its results are not reported\n", simulationIndex)
+		return false, ctx.Err()
+	}
 
-				err = writer.Close()
-				if err != nil {
-					Fatal(err)
-				}
+	var resultJson []byte
+	if simulationRunResults.Results != nil {
+		resultJson, _ = json.Marshal(simulationRunResults.Results)
+	}
 
-				binariesUploadUrl := fmt.Sprintf("experiments/%s/simulations/%v", experimentID, simulationIndex)
-				binariesUploadUrlInfo := RequestInfo{"PUT", requestBody, writer.FormDataContentType(), binariesUploadUrl}
-				body := sim.ExecuteScalarmRequest(binariesUploadUrlInfo, storageManagers, sim.HttpClient, communicationTimeout)
+	// 4f. upload structural results of a simulation run
+	data := url.Values{}
+	data.Set("status", simulationRunResults.Status)
+	data.Add("reason", simulationRunResults.Reason)
+	data.Add("result", string(resultJson))
+
+	fmt.Printf("[SiM] Results: %v\n", data)
+
+	entries := []*OutboxEntry{{
+		Kind:            OutboxMarkAsComplete,
+		ExperimentID:    run.ExperimentID,
+		SimulationIndex: simulationIndex,
+		Values:          data}}
+
+	// 4g. upload binary output if provided
+	outputArchivePath := path.Join(simulationDirPath, "output.tar.gz")
+	if _, err := os.Stat(outputArchivePath); err == nil {
+		entries = append(entries, &OutboxEntry{
+			Kind:            OutboxOutput,
+			ExperimentID:    run.ExperimentID,
+			SimulationIndex: simulationIndex,
+			FilePath:        outputArchivePath})
+	}
 
-				fmt.Printf("[SiM] Response body: %s\n", body)
-			}
+	// 4h. upload stdout if provided
+	if _, err := os.Stat(stdoutPath); err == nil {
+		entries = append(entries, &OutboxEntry{
+			Kind:            OutboxStdout,
+			ExperimentID:    run.ExperimentID,
+			SimulationIndex: simulationIndex,
+			FilePath:        stdoutPath})
+	}
 
-			// 4h. upload stdout if provided
-			if _, err := os.Stat("_stdout.txt"); err == nil {
-				fmt.Println("[SiM] Uploading STDOUT of the simulation run ...")
+	// results are journalled before they are sent, so they are not lost when Scalarm is unreachable
+	for _, entry := range entries {
+		if err := run.Outbox.Add(entry); err != nil {
+			fmt.Println("[SiM] Error during saving results of the simulation run.")
+			return false, err
+		}
+	}
 
-				file, err := os.Open("_stdout.txt")
-				if err != nil {
-					Fatal(err)
-				}
+	if err := run.Outbox.FlushSimulationRun(ctx, run.ExperimentID, simulationIndex); ctx.Err() != nil {
+		return false, ctx.Err()
+	} else if err != nil {
+		fmt.Printf("[SiM] Results of simulation run %v are kept in outbox: %v\n", simulationIndex, err)
+	}
 
-				requestBody := &bytes.Buffer{}
-				writer := multipart.NewWriter(requestBody)
-				part, err := writer.CreateFormFile("file", filepath.Base("_stdout.txt"))
-				if err != nil {
-					Fatal(err)
-				}
-				_, err = io.Copy(part, file)
-				file.Close()
+	// 5. clean up - removing simulation dir
+	os.RemoveAll(simulationDirPath)
 
-				err = writer.Close()
-				if err != nil {
-					Fatal(err)
-				}
+	return adapterErr == nil, nil
+}
 
-				stdoutUploadUrl := fmt.Sprintf("experiments/%s/simulations/%v/stdout", experimentID, simulationIndex)
-				stdoutUploadUrlInfo := RequestInfo{"PUT", requestBody, writer.FormDataContentType(), stdoutUploadUrl}
-				body := sim.ExecuteScalarmRequest(stdoutUploadUrlInfo, storageManagers, sim.HttpClient, communicationTimeout)
+// processSimulationRun executes the whole adapters pipeline of a simulation run in the given directory:
+// input_writer, executor with process and progress monitoring, output_reader and output.json validation;
+// the reporter receives host info, performance statistics and progress info of the run
+func (sim SimulationManager) processSimulationRun(ctx context.Context, reporter ProgressReporter, codeBaseDir, simulationDirPath string,
+	simulationRun *SimulationRunConfig) (*SimulationRunResults, error) {
 
-				fmt.Printf("[SiM] Response body: %s\n", body)
-			}
+	simulationIndex := simulationRun.SimulationID
 
-			// 5. clean up - removing simulation dir
-			go func() {
-				select {
-				case _ = <-finished:
-					os.RemoveAll(simulationDirPath)
-					close(finished)
-				}
-			}()
+	err := os.MkdirAll(simulationDirPath, 0777)
+	if err == nil {
+		inputParameters, _ := json.Marshal(simulationRun.InputParameters)
+		err = ioutil.WriteFile(path.Join(simulationDirPath, "input.json"), inputParameters, 0777)
+	}
+
+	if err != nil {
+		fmt.Printf("[SiM] Could not prepare directory of simulation run %v: %v\n", simulationIndex, err)
+		return &SimulationRunResults{Status: "error", Reason: err.Error()}, err
+	}
+
+	fmt.Printf("[SiM] Working dir: %v\n", simulationDirPath)
+
+	// 4b. run an adapter script (input writer) for input information: input.json -> some specific code
+	adapterErr := RunAdapterContext(ctx, "input_writer", codeBaseDir, simulationDirPath, "input.json")
+
+	var timeConstraint time.Duration
+	executorStatus := executorFinished
+
+	if adapterErr == nil {
+		// 4c.1. progress monitoring scheduling if available
+		messages := make(chan struct{}, 1)
+		finished := make(chan error, 1)
+		go sim.IntermediateMonitoring(ctx, messages, finished, codeBaseDir, reporter, simulationIndex, simulationDirPath)
+
+		// 4c. run an executor of this simulation
+		timeConstraint = simulationRun.TimeConstraint()
+		executorStatus, adapterErr = sim.runExecutor(ctx, reporter, simulationIndex, codeBaseDir, simulationDirPath, timeConstraint)
+
+		messages <- struct{}{}
+		close(messages)
+
+		if monitoringErr := <-finished; monitoringErr != nil && adapterErr == nil {
+			adapterErr = monitoringErr
+		}
+	}
+
+	// 4d. run an adapter script (output reader) to transform specific output format to scalarm model (output.json)
+	if adapterErr == nil && executorStatus != executorInterrupted && executorStatus != executorCanceled {
+		adapterErr = RunAdapterContext(ctx, "output_reader", codeBaseDir, simulationDirPath, "")
+	}
+
+	// 4e. read and validate output.json
+	simulationRunResults := new(SimulationRunResults)
+	outputJSONPath := path.Join(simulationDirPath, "output.json")
+
+	if _, err := os.Stat(outputJSONPath); os.IsNotExist(err) {
+		simulationRunResults.Status = "error"
+		simulationRunResults.Reason = fmt.Sprintf("No output.json file found: %s", err.Error())
+	} else {
+		file, err := os.Open(outputJSONPath)
+
+		if err != nil {
+			simulationRunResults.Status = "error"
+			simulationRunResults.Reason = fmt.Sprintf("Could not open output.json: %s", err.Error())
+		} else {
+			err = json.NewDecoder(file).Decode(&simulationRunResults)
 
-			rootDir, err := os.Open(sim.RootDirPath)
 			if err != nil {
-				Fatal(err)
+				simulationRunResults.Status = "error"
+				simulationRunResults.Reason = fmt.Sprintf("Error during output.json parsing: %s", err.Error())
 			}
+		}
 
-			// 6. going to the root dir and moving
-			if err = rootDir.Chdir(); err != nil {
-				Fatal(err)
-			}
+		file.Close()
+	}
 
-			simulationsDone++
+	resultJson, _ := json.Marshal(simulationRunResults.Results)
 
-			if simulationsLimit > 0 {
-				fmt.Printf("[SiM] Simulations done: %v/%v\n", simulationsDone, simulationsLimit)
-			}
+	if !simulationRunResults.isValid() || !IsJSON(string(resultJson)) {
+		fmt.Printf("[output.json] Invalid results.json: %s\n", resultJson)
+		simulationRunResults.Status = "error"
+		simulationRunResults.Results = nil
+		simulationRunResults.Reason = fmt.Sprintf("Invalid results.json: %s", resultJson)
+	}
+
+	if executorStatus == executorCanceled || ctx.Err() != nil {
+		simulationRunResults.Status = "error"
+		simulationRunResults.Results = nil
+		simulationRunResults.Reason = "Simulation run has been canceled"
+	} else if executorStatus == executorTimedOut {
+		simulationRunResults.Status = "error"
+		simulationRunResults.Results = nil
+		simulationRunResults.Reason = fmt.Sprintf("Simulation run exceeded time constraint of %v seconds and has been killed", timeConstraint.Seconds())
+	} else if executorStatus == executorInterrupted && simulationRunResults.Status != "ok" {
+		// partial results from output.json are reported as they are
+		simulationRunResults.Reason = fmt.Sprintf("Simulation run has been interrupted by %v signal", sim.shutdown.signal)
+	} else if adapterErr != nil {
+		simulationRunResults.Status = "error"
+		simulationRunResults.Results = nil
+		simulationRunResults.Reason = adapterErr.Error()
+	}
+
+	return simulationRunResults, adapterErr
+}
 
-			if simulationsLimit > 0 && simulationsDone >= simulationsLimit {
-				fmt.Printf("[SiM] Exiting due to simulation runs limit (%v)\n", simulationsLimit)
-				os.Exit(1)
+// possible outcomes of the executor apart from its own failure
+const (
+	executorFinished = iota
+	executorTimedOut
+	executorInterrupted
+	executorCanceled
+)
+
+// runExecutor executes the executor adapter in its own process group and waits for it to finish,
+// the whole process tree is killed when the run exceeds its time constraint or ctx is done
+// and signalled when the worker is shutting down
+func (sim SimulationManager) runExecutor(ctx context.Context, reporter ProgressReporter, simulationIndex int, codeBaseDir, simulationDirPath string,
+	timeConstraint time.Duration) (int, error) {
+
+	stdoutPath := path.Join(simulationDirPath, "_stdout.txt")
+
+	fmt.Println("[SiM] Before executor ...")
+	// the shell is replaced with the executor, so signals forwarded to the executor are not lost on the shell
+	executorCmd := exec.Command("sh", "-c", "exec "+path.Join(codeBaseDir, "executor >>_stdout.txt 2>&1"))
+	executorCmd.Dir = simulationDirPath
+	SetProcessGroup(executorCmd)
+	if err := executorCmd.Start(); err != nil {
+		return executorFinished, NewAdapterError("executor", executorCmd, err, stdoutPath)
+	}
+	pid := executorCmd.Process.Pid
+	sim.shutdown.addExecutor(pid)
+
+	var status int32 = executorFinished
+
+	// 4c.2. killing the whole executor process tree when the time constraint is exceeded
+	if timeConstraint > 0 {
+		timer := time.AfterFunc(timeConstraint, func() {
+			atomic.StoreInt32(&status, executorTimedOut)
+			fmt.Printf("[SiM] Simulation run %v exceeded its time constraint (%v), killing the executor\n", simulationIndex, timeConstraint)
+			if err := SignalProcessGroup(pid, syscall.SIGKILL); err != nil {
+				fmt.Printf("[SiM] Could not kill the executor: %v\n", err)
 			}
+		})
+		defer timer.Stop()
+	}
+
+	// process monitoring has to stop as soon as the executor is reaped - its pid exists until then
+	executorErr := make(chan error, 1)
+	executorDone := make(chan struct{})
+	go func() {
+		err := executorCmd.Wait()
+		// the process group must not be killed once its pid can be reused
+		sim.shutdown.removeExecutor(pid)
+		executorErr <- err
+		close(executorDone)
+	}()
+
+	// 4c.3. forwarding the shutdown signal to the executor and killing it after the grace period or on cancellation
+	go func() {
+		select {
+		case <-executorDone:
+			return
+		case <-ctx.Done():
+			sim.cancelExecutor(&status, pid, simulationIndex)
+			return
+		case <-sim.shutdown.requested:
+		}
+
+		atomic.CompareAndSwapInt32(&status, executorFinished, executorInterrupted)
+		fmt.Printf("[SiM] Forwarding %v signal to the executor of simulation run %v\n", sim.shutdown.signal, simulationIndex)
+		SignalProcessGroup(pid, sim.shutdown.signal)
+
+		select {
+		case <-executorDone:
+		case <-ctx.Done():
+			sim.cancelExecutor(&status, pid, simulationIndex)
+		case <-time.After(time.Duration(sim.Config.ShutdownGracePeriod) * time.Second):
+			fmt.Printf("[SiM] Executor of simulation run %v did not finish within the grace period, killing it\n", simulationIndex)
+			SignalProcessGroup(pid, syscall.SIGKILL)
 		}
+	}()
+
+	RunProcessMonitoring(ctx, pid, &sim, reporter, simulationIndex, executorDone)
+
+	err := <-executorErr
+	if finalStatus := int(atomic.LoadInt32(&status)); finalStatus != executorFinished {
+		return finalStatus, nil
+	}
+
+	if err != nil {
+		return executorFinished, NewAdapterError("executor", executorCmd, err, stdoutPath)
+	}
+
+	fmt.Println("[SiM] After executor ...")
+	return executorFinished, nil
+}
+
+// cancelExecutor kills the whole executor process tree when the root context is done
+func (sim SimulationManager) cancelExecutor(status *int32, pid, simulationIndex int) {
+	atomic.StoreInt32(status, executorCanceled)
+	fmt.Printf("[SiM] Simulation run %v has been canceled, killing the executor\n", simulationIndex)
+	if err := SignalProcessGroup(pid, syscall.SIGKILL); err != nil {
+		fmt.Printf("[SiM] Could not kill the executor: %v\n", err)
 	}
 }
 
+// TimeConstraint returns the time_constraint_in_sec execution constraint of a simulation run
+// or 0 if the run is not constrained
+func (simulationRun *SimulationRunConfig) TimeConstraint() time.Duration {
+	seconds := simulationRun.ExecutionConstraints.TimeConstraintInSec
+	if seconds <= 0 {
+		return 0
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// CanFinishBefore returns false when the simulation run would exceed the deadline if it used its whole time constraint,
+// a run without a time constraint or a deadline is always expected to finish
+func (simulationRun *SimulationRunConfig) CanFinishBefore(deadline time.Time, now time.Time) bool {
+	if deadline.IsZero() || simulationRun.TimeConstraint() == 0 {
+		return true
+	}
+
+	return deadline.Sub(now) >= simulationRun.TimeConstraint()
+}
+
 func Extract(zip_path, dest string) error {
 	r, err := zip.OpenReader(zip_path)
 	if err != nil {
@@ -569,12 +990,10 @@ func Extract(zip_path, dest string) error {
 	return nil
 }
 
-func PrintStdoutLog() {
-	linesNum := "100" // TODO: make int strconv.Itoa(linesNum)
-	stdoutPath := "_stdout.txt"
-	out, _ := exec.Command("tail", "-n", linesNum, stdoutPath).CombinedOutput()
+func PrintStdoutLog(stdoutPath string) {
+	linesNum := 100
 	fmt.Printf("----------\nLast %v lines of %v:\n----------\n", linesNum, stdoutPath)
-	fmt.Println(string(out))
+	fmt.Println(StdoutTail(stdoutPath, linesNum))
 }
 
 func cloneZipItem(f *zip.File, dest string) error {
@@ -1,41 +1,172 @@
 package scalarmWorker
 
 import (
-	"encoding/json"
 	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 )
 
+// prefix of environment variables overriding config values, e.g. SCALARM_EXPERIMENT_MANAGER_PASS
+const configEnvPrefix = "SCALARM_"
+
+// default location of the config file
+const defaultConfigPath = "config.json"
+
 // Config file description - this should be provided by Experiment Manager in 'config.json'
+// every value can be overridden with a flag named as its json key or with an environment variable
+// named as its json key in upper case prefixed with SCALARM_
 type SimulationManagerConfig struct {
-	ExperimentId           string `json:"experiment_id"`
-	InformationServiceUrl  string `json:"information_service_url"`
-	ExperimentManagerUser  string `json:"experiment_manager_user"`
-	ExperimentManagerPass  string `json:"experiment_manager_pass"`
-	Development            bool   `json:"development"`
-	StartAt                string `json:"start_at"`
-	Timeout                int    `json:"timeout"`
-	ScalarmCertificatePath string `json:"scalarm_certificate_path"`
-	SimulationsLimit       int    `json:"simulations_limit"`
-	InsecureSSL            bool   `json:"insecure_ssl"`
-	MonitoringInterval     int    `json:"monitoring_interval"`
-	CooldownInterval       int    `json:"cooldown_interval"`
+	ExperimentId                  string   `json:"experiment_id" usage:"experiment to compute, random user's experiments if empty"`
+	InformationServiceUrl         string   `json:"information_service_url" usage:"address of Information Service"`
+	ExperimentManagerUser         string   `json:"experiment_manager_user" usage:"user name used to authenticate in Scalarm services"`
+	ExperimentManagerPass         string   `json:"experiment_manager_pass" usage:"password used to authenticate in Scalarm services"`
+	AuthMethod                    string   `json:"auth_method" usage:"authentication in Scalarm services: basic, token, token_file or proxy_certificate (default basic)"`
+	AuthToken                     string   `json:"auth_token" usage:"bearer token used with the 'token' authentication"`
+	AuthTokenFile                 string   `json:"auth_token_file" usage:"file with a bearer token used with the 'token_file' authentication, read before every request"`
+	ProxyCertificatePath          string   `json:"proxy_certificate_path" usage:"file with a Scalarm proxy certificate used with the 'proxy_certificate' authentication"`
+	Development                   bool     `json:"development" usage:"use HTTP instead of HTTPS"`
+	StartAt                       string   `json:"start_at" usage:"time (RFC3339) to start work at"`
+	Timeout                       int      `json:"timeout" usage:"communication timeout in seconds"`
+	ScalarmCertificatePath        string   `json:"scalarm_certificate_path" usage:"path to CA certificate of Scalarm services, trusted instead of the system ones"`
+	SimulationsLimit              int      `json:"simulations_limit" usage:"max number of simulation run to execute"`
+	InsecureSSL                   bool     `json:"insecure_ssl" usage:"skip verification of Scalarm services certificates"`
+	CACertificatePaths            []string `json:"ca_certificate_paths" usage:"comma separated CA certificate files appended to the system ones"`
+	CACertificateDir              string   `json:"ca_certificate_dir" usage:"directory with CA certificate files appended to the system ones"`
+	ClientCertificatePath         string   `json:"client_certificate_path" usage:"client certificate used for mutual TLS with Scalarm services"`
+	ClientKeyPath                 string   `json:"client_key_path" usage:"private key of the client certificate"`
+	TLSMinVersion                 string   `json:"tls_min_version" usage:"minimum TLS version: 1.0, 1.1, 1.2 or 1.3"`
+	ProxyURL                      string   `json:"proxy_url" usage:"proxy of connections to Scalarm services, 'none' disables proxies (default from HTTPS_PROXY, HTTP_PROXY and NO_PROXY)"`
+	ConnectTimeout                int      `json:"connect_timeout" usage:"max time in seconds of establishing a connection (default 30)"`
+	TLSHandshakeTimeout           int      `json:"tls_handshake_timeout" usage:"max time in seconds of a TLS handshake (default 10)"`
+	ResponseHeaderTimeout         int      `json:"response_header_timeout" usage:"max time in seconds of waiting for response headers after a request is sent (default no limit)"`
+	KeepAlive                     int      `json:"keep_alive" usage:"interval in seconds of TCP keep-alive probes (default 30)"`
+	MaxIdleConnections            int      `json:"max_idle_connections" usage:"max number of idle connections kept for reuse (default 100)"`
+	MaxIdleConnectionsPerHost     int      `json:"max_idle_connections_per_host" usage:"max number of idle connections kept for reuse per host (default 2)"`
+	IdleConnectionTimeout         int      `json:"idle_connection_timeout" usage:"time in seconds after which an idle connection is closed (default 90)"`
+	MonitoringInterval            int      `json:"monitoring_interval" usage:"interval in seconds of reporting performance statistics"`
+	CooldownInterval              int      `json:"cooldown_interval" usage:"interval in seconds between retries of failed operations"`
+	ParallelSlots                 int      `json:"parallel_slots" usage:"number of simulation runs executed concurrently"`
+	MaxConsecutiveFailures        int      `json:"max_consecutive_failures" usage:"number of consecutive failed simulation runs after which the worker gives up"`
+	ShutdownGracePeriod           int      `json:"shutdown_grace_period" usage:"seconds given to running executors to finish on SIGTERM or SIGINT"`
+	Deadline                      string   `json:"deadline" usage:"time (RFC3339) or duration after which no new simulation run is started"`
+	RetryMaxAttempts              int      `json:"retry_max_attempts" usage:"max number of attempts of a request to Scalarm services (default 5)"`
+	RetryInitialBackoff           int      `json:"retry_initial_backoff" usage:"max time in seconds to wait before the first retry, doubled with every attempt (default 1)"`
+	RetryMaxBackoff               int      `json:"retry_max_backoff" usage:"max time in seconds to wait between retries (default 30)"`
+	RetryStatusCodes              []int    `json:"retry_status_codes" usage:"comma separated response codes which are retried (default 429,502,503,504)"`
+	ManagersRefreshInterval       int      `json:"managers_refresh_interval" usage:"interval in seconds of refreshing Experiment and Storage Managers from Information Service (default 300)"`
+	InformationServiceCachePath   string   `json:"information_service_cache_path" usage:"file caching answers of Information Service (default \".information_service_cache.json\" in the working directory)"`
+	InformationServiceCacheMaxAge int      `json:"information_service_cache_max_age" usage:"max age in seconds of cached answers used when Information Service is unavailable (default 604800)"`
+	ExperimentManagerUrls         []string `json:"experiment_manager_urls" usage:"comma separated addresses of Experiment Managers, Information Service is not asked for them if given"`
+	StorageManagerUrls            []string `json:"storage_manager_urls" usage:"comma separated addresses of Storage Managers, Information Service is not asked for them if given"`
+	UploadSizeLimit               int      `json:"upload_size_limit" usage:"max size in MB of output.tar.gz or _stdout.txt uploaded to Storage Manager, bigger files are not uploaded (default no limit)"`
+	OutboxDir                     string   `json:"outbox_dir" usage:"directory keeping results until they are sent to Scalarm (default \"outbox\" in the working directory)"`
+	OutboxMaxAttempts             int      `json:"outbox_max_attempts" usage:"max number of attempts of sending a result kept in outbox before it is moved to dead letters (default 20)"`
+	OutboxMaxAge                  int      `json:"outbox_max_age" usage:"max age in seconds of a result kept in outbox before it is moved to dead letters (default 604800)"`
+	OutboxDrainTimeout            int      `json:"outbox_drain_timeout" usage:"max time in seconds of sending results left in outbox before new simulation runs are fetched (default 300)"`
 }
 
 func CreateSimulationManagerConfig(filePath string) (*SimulationManagerConfig, error) {
-	configFile, err := os.Open(filePath)
-	if err != nil {
-		return nil, errors.New("Could not open file " + filePath + ".")
+	config := new(SimulationManagerConfig)
+
+	if err := readConfigFile(filePath, config); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	config.setDefaults()
+
+	return config, nil
+}
+
+// LoadSimulationManagerConfig creates and validates config from command line arguments, environment variables
+// and the config file.
+// Precedence of values: flag > environment variable > config file > default value.
+// The config file is read from the -config flag, SCALARM_CONFIG variable or 'config.json',
+// the latter is optional when the config is provided with flags or environment variables.
+func LoadSimulationManagerConfig(args []string, getenv func(string) string) (*SimulationManagerConfig, error) {
+	flags := flag.NewFlagSet("scalarm_simulation_manager", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to the config file (default \""+defaultConfigPath+"\")")
+
+	configValue := reflect.ValueOf(&SimulationManagerConfig{}).Elem()
+	flagValues := map[string]*configFlag{}
+	for i := 0; i < configValue.NumField(); i++ {
+		field := configValue.Type().Field(i)
+		key := field.Tag.Get("json")
+		flagValues[key] = &configFlag{isBool: field.Type.Kind() == reflect.Bool}
+		flags.Var(flagValues[key], key, field.Tag.Get("usage"))
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
 	}
 
 	config := new(SimulationManagerConfig)
-	err = json.NewDecoder(configFile).Decode(&config)
-	configFile.Close()
 
+	// 1. config file
+	if *configPath == "" {
+		*configPath = getenv(configEnvPrefix + "CONFIG")
+	}
+
+	if *configPath != "" {
+		if err := readConfigFile(*configPath, config); err != nil {
+			return nil, err
+		}
+	} else if _, err := os.Stat(defaultConfigPath); err == nil {
+		if err := readConfigFile(defaultConfigPath, config); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. environment variables
+	configValue = reflect.ValueOf(config).Elem()
+	for i := 0; i < configValue.NumField(); i++ {
+		envName := configEnvPrefix + strings.ToUpper(configValue.Type().Field(i).Tag.Get("json"))
+
+		if value := getenv(envName); value != "" {
+			if err := setConfigField(configValue.Field(i), value); err != nil {
+				return nil, fmt.Errorf("Incorrect value of %s: %v", envName, err)
+			}
+		}
+	}
+
+	// 3. flags
+	for i := 0; i < configValue.NumField(); i++ {
+		key := configValue.Type().Field(i).Tag.Get("json")
+
+		if flagValue := flagValues[key]; flagValue.set {
+			if err := setConfigField(configValue.Field(i), flagValue.value); err != nil {
+				return nil, fmt.Errorf("Incorrect value of -%s: %v", key, err)
+			}
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	config.setDefaults()
+
+	return config, nil
+}
+
+func readConfigFile(filePath string, config *SimulationManagerConfig) error {
+	content, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		return nil, errors.New("Incorrect JSON in the file.")
+		return errors.New("Could not open file " + filePath + ".")
 	}
 
+	return decodeConfig(content, config)
+}
+
+func (config *SimulationManagerConfig) setDefaults() {
 	if config.SimulationsLimit <= 0 {
 		config.SimulationsLimit = -1
 	}
@@ -44,5 +175,103 @@ func CreateSimulationManagerConfig(filePath string) (*SimulationManagerConfig, e
 		config.Timeout = 60
 	}
 
-	return config, nil
+	if config.ParallelSlots <= 0 {
+		config.ParallelSlots = 1
+	}
+
+	if config.CooldownInterval <= 0 {
+		config.CooldownInterval = 5
+	}
+
+	if config.MaxConsecutiveFailures <= 0 {
+		config.MaxConsecutiveFailures = 5
+	}
+
+	if config.ShutdownGracePeriod <= 0 {
+		config.ShutdownGracePeriod = 30
+	}
+
+	if config.ManagersRefreshInterval <= 0 {
+		config.ManagersRefreshInterval = 300
+	}
+
+	if config.OutboxMaxAttempts <= 0 {
+		config.OutboxMaxAttempts = 20
+	}
+
+	if config.OutboxMaxAge <= 0 {
+		config.OutboxMaxAge = 7 * 24 * 3600
+	}
+
+	if config.OutboxDrainTimeout <= 0 {
+		config.OutboxDrainTimeout = 300
+	}
+
+	if config.InformationServiceCacheMaxAge <= 0 {
+		config.InformationServiceCacheMaxAge = 7 * 24 * 3600
+	}
+}
+
+// setConfigField sets a config value from its text representation
+func setConfigField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(boolValue)
+	case reflect.Int:
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(intValue))
+	case reflect.Slice:
+		// lists are given as comma separated values
+		values := strings.Split(value, ",")
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, element := range values {
+			if err := setConfigField(slice.Index(i), strings.TrimSpace(element)); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+	default:
+		return errors.New("unsupported config value type " + field.Kind().String())
+	}
+
+	return nil
+}
+
+// configFlag remembers a config value given in the command line to apply it after the config file and environment
+type configFlag struct {
+	value  string
+	set    bool
+	isBool bool
+}
+
+func (f *configFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *configFlag) Set(value string) error {
+	f.value = value
+	f.set = true
+	return nil
+}
+
+// IsBoolFlag allows to use boolean flags without a value, e.g. -development
+func (f *configFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// UsesInformationService returns false when addresses of both Experiment and Storage Managers are given in the config
+func (config *SimulationManagerConfig) UsesInformationService() bool {
+	return len(config.ExperimentManagerUrls) == 0 || len(config.StorageManagerUrls) == 0
 }
@@ -1,6 +1,7 @@
 package scalarmWorker
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -33,7 +34,7 @@ func TestHandlingCorrectSimulationManagerConfigWithoutTimeout(t *testing.T) {
 func TestHandlingNoFileToCreateSimulationManagerConfig(t *testing.T) {
 	_, err := CreateSimulationManagerConfig("test_assets/does_not_exist.json")
 	if err == nil {
-		t.Errorf("Got: nil - Expected not nil", err)
+		t.Fatalf("Got: nil - Expected not nil")
 	}
 
 	expected_msg := "Could not open file test_assets/does_not_exist.json."
@@ -46,12 +47,118 @@ func TestHandlingNoFileToCreateSimulationManagerConfig(t *testing.T) {
 func TestHandlingIncorrectSimulationManagerConfig(t *testing.T) {
 	_, err := CreateSimulationManagerConfig("test_assets/incorrect_input.json")
 	if err == nil {
-		t.Errorf("Got: nil - Expected not nil", err)
+		t.Fatalf("Got: nil - Expected not nil")
 	}
 
-	expected_msg := "Incorrect JSON in the file."
+	expected_msg := "Incorrect JSON in the file at line 6, column 1: unexpected end of JSON input"
 
 	if err.Error() != expected_msg {
 		t.Errorf("Got: '%v' - Expected '%v'", err.Error(), expected_msg)
 	}
 }
+
+func TestLoadingSimulationManagerConfigShouldApplyEnvironmentAndFlagsOverConfigFile(t *testing.T) {
+	env := map[string]string{
+		"SCALARM_EXPERIMENT_MANAGER_USER": "env_user",
+		"SCALARM_EXPERIMENT_MANAGER_PASS": "env_password",
+		"SCALARM_TIMEOUT":                 "30",
+	}
+	args := []string{"-config", "test_assets/correct_input.json", "-experiment_manager_pass", "flag_password", "-development"}
+
+	config, err := LoadSimulationManagerConfig(args, func(key string) string { return env[key] })
+
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	expected := SimulationManagerConfig{
+		ExperimentId:          "54e4d4fd4269a870f7004b01",
+		InformationServiceUrl: "127.0.0.1:11300",
+		ExperimentManagerUser: "env_user",
+		ExperimentManagerPass: "flag_password",
+		Development:           true,
+		Timeout:               30,
+		SimulationsLimit:      -1,
+		InsecureSSL:           true,
+		ParallelSlots:         1,
+
+		CooldownInterval:              5,
+		MaxConsecutiveFailures:        5,
+		ShutdownGracePeriod:           30,
+		ManagersRefreshInterval:       300,
+		InformationServiceCacheMaxAge: 7 * 24 * 3600,
+		OutboxMaxAttempts:             20,
+		OutboxMaxAge:                  7 * 24 * 3600,
+		OutboxDrainTimeout:            300,
+	}
+
+	if !reflect.DeepEqual(*config, expected) {
+		t.Errorf("Got: '%+v' - Expected '%+v'", *config, expected)
+	}
+}
+
+func TestLoadingSimulationManagerConfigShouldReadConfigPathFromEnvironment(t *testing.T) {
+	env := map[string]string{"SCALARM_CONFIG": "test_assets/correct_input.json"}
+
+	config, err := LoadSimulationManagerConfig([]string{}, func(key string) string { return env[key] })
+
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	if config.ExperimentManagerUser != "really_secret_user" {
+		t.Errorf("Got: '%v' - Expected 'really_secret_user'", config.ExperimentManagerUser)
+	}
+}
+
+func TestLoadingSimulationManagerConfigShouldNotRequireDefaultConfigFile(t *testing.T) {
+	env := map[string]string{
+		"SCALARM_INFORMATION_SERVICE_URL": "scalarm.com/information",
+		"SCALARM_EXPERIMENT_MANAGER_USER": "user",
+		"SCALARM_EXPERIMENT_MANAGER_PASS": "pass",
+	}
+
+	config, err := LoadSimulationManagerConfig([]string{"-simulations_limit", "3"}, func(key string) string { return env[key] })
+
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	if config.InformationServiceUrl != "scalarm.com/information" || config.SimulationsLimit != 3 || config.Timeout != 60 {
+		t.Errorf("Got: '%+v' - Expected values from environment, flags and defaults", *config)
+	}
+}
+
+func TestLoadingSimulationManagerConfigShouldFailOnMissingConfigFileGivenExplicitly(t *testing.T) {
+	_, err := LoadSimulationManagerConfig([]string{"-config", "test_assets/does_not_exist.json"}, func(string) string { return "" })
+
+	expected_msg := "Could not open file test_assets/does_not_exist.json."
+	if err == nil || err.Error() != expected_msg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expected_msg)
+	}
+}
+
+func TestLoadingSimulationManagerConfigShouldFailOnIncorrectEnvironmentValue(t *testing.T) {
+	env := map[string]string{"SCALARM_TIMEOUT": "a minute"}
+
+	_, err := LoadSimulationManagerConfig([]string{}, func(key string) string { return env[key] })
+
+	expected_msg := "Incorrect value of SCALARM_TIMEOUT: strconv.Atoi: parsing \"a minute\": invalid syntax"
+	if err == nil || err.Error() != expected_msg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expected_msg)
+	}
+}
+
+func TestLoadingSimulationManagerConfigShouldParseListsFromFlags(t *testing.T) {
+	args := []string{"-config", "test_assets/correct_input.json", "-retry_status_codes", "503, 504"}
+
+	config, err := LoadSimulationManagerConfig(args, func(string) string { return "" })
+
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	if !reflect.DeepEqual(config.RetryStatusCodes, []int{503, 504}) {
+		t.Errorf("Got: '%v' - Expected '[503 504]'", config.RetryStatusCodes)
+	}
+}
@@ -1,19 +1,76 @@
 package scalarmWorker
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/scalarm/scalarm_simulation_manager_go/fakeScalarm"
 )
 
+// =========== UTILS/SETUP ===========
+
+func createZip(t *testing.T, files map[string][]byte) []byte {
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+
+	for name, content := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write(content)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+// createCodeBase returns a code base archive with the given adapter scripts
+func createCodeBase(t *testing.T, adapters map[string]string) []byte {
+	files := map[string][]byte{"simulation_binaries.zip": createZip(t, map[string][]byte{})}
+	for name, script := range adapters {
+		files[name] = []byte(script)
+	}
+
+	return createZip(t, files)
+}
+
+// fetchedSimulationRuns returns how many times the worker asked the fake for the next simulation run of the experiment
+func fetchedSimulationRuns(fake *fakeScalarm.Server, experimentID string) int {
+	fetched := 0
+	for _, request := range fake.Requests() {
+		if request == "GET /experiments/"+experimentID+"/next_simulation" {
+			fetched++
+		}
+	}
+
+	return fetched
+}
+
+// =========== =========== ===========
+
 func TestSimRunShouldRunSimulationsFromExperiment(t *testing.T) {
 	// === GIVEN ===
-	os.RemoveAll("./experiment_1")
-	defer os.RemoveAll("./experiment_1")
+	rootDir, _ := ioutil.TempDir("", "scalarm_sim_test")
+	defer os.RemoveAll(rootDir)
 	hostInfoSent := false
 	performanceStatsSent := false
 	allSimulationsSent := false
@@ -64,7 +121,7 @@ func TestSimRunShouldRunSimulationsFromExperiment(t *testing.T) {
 				}
 			}
 
-		} else if r.URL.Path == "/experiments/1/simulations/1/stdout" && r.Method == "POST" {
+		} else if r.URL.Path == "/experiments/1/simulations/1/stdout" && r.Method == "PUT" {
 			w.WriteHeader(200)
 		} else if r.URL.Path == "/experiments/1/simulations/1/host_info" && r.Method == "POST" {
 			hostInfoSent = true
@@ -88,24 +145,24 @@ func TestSimRunShouldRunSimulationsFromExperiment(t *testing.T) {
 	}
 
 	config := SimulationManagerConfig{
-		ExperimentId:           "1",
-		InformationServiceUrl:  "www.example.com/information",
-		ExperimentManagerUser:  "user",
-		ExperimentManagerPass:  "pass",
-		Development:            true,
-		Timeout:                2,
-		ScalarmCertificatePath: "",
-		InsecureSSL:            true,
-		MonitoringInterval:     1,
-		CooldownInterval:       1,
+		ExperimentId:                "1",
+		InformationServiceUrl:       "www.example.com/information",
+		ExperimentManagerUser:       "user",
+		ExperimentManagerPass:       "pass",
+		Development:                 true,
+		Timeout:                     2,
+		ScalarmCertificatePath:      "",
+		InsecureSSL:                 true,
+		MonitoringInterval:          1,
+		CooldownInterval:            1,
+		OutboxDir:                   path.Join(rootDir, "outbox"),
+		InformationServiceCachePath: path.Join(rootDir, ".information_service_cache.json"),
 	}
 
-	wd, _ := os.Getwd()
-
 	sim := SimulationManager{
 		Config:      &config,
 		HttpClient:  &http.Client{Transport: transport},
-		RootDirPath: wd,
+		RootDirPath: rootDir,
 	}
 
 	sim.Run()
@@ -118,3 +175,558 @@ func TestSimRunShouldRunSimulationsFromExperiment(t *testing.T) {
 		t.Errorf("Performance information has not been sent")
 	}
 }
+
+func TestSimRunShouldExecuteSimulationRunsConcurrentlyInParallelSlots(t *testing.T) {
+	// === GIVEN ===
+	rootDir, _ := ioutil.TempDir("", "scalarm_sim_test")
+	defer os.RemoveAll(rootDir)
+
+	codeBase := createCodeBase(t, map[string]string{
+		"executor": "#!/bin/sh\nsleep 1\necho '{\"status\":\"ok\",\"results\":{\"product\":1}}' > output.json\n",
+	})
+
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{
+		ID:              "2",
+		CodeBase:        codeBase,
+		InputParameters: []map[string]interface{}{{"parameter1": 1}, {"parameter1": 2}, {"parameter1": 3}, {"parameter1": 4}},
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := SimulationManagerConfig{
+		ExperimentId:          "2",
+		InformationServiceUrl: "www.example.com/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		Development:           true,
+		Timeout:               2,
+		CooldownInterval:      1,
+		ParallelSlots:         2,
+	}
+
+	sim := SimulationManager{
+		Config:      &config,
+		HttpClient:  getHttpClientMock(server.URL),
+		RootDirPath: rootDir,
+	}
+
+	// === WHEN ===
+	sim.Run()
+
+	// === THEN ===
+	if completions := fake.Completions(); len(completions) != 4 {
+		t.Errorf("Got: %v completed simulation runs - Expected 4", len(completions))
+	}
+
+	for _, completion := range fake.Completions() {
+		if completion.Values.Get("status") != "ok" {
+			t.Errorf("Simulation run %v has not been completed successfully", completion.SimulationID)
+		}
+	}
+
+	// every run lasts a second, so both slots fetch a run before the first one is completed
+	fetchedBeforeCompletion := 0
+	for _, request := range fake.Requests() {
+		if strings.HasSuffix(request, "/mark_as_complete") {
+			break
+		} else if request == "GET /experiments/2/next_simulation" {
+			fetchedBeforeCompletion++
+		}
+	}
+
+	if fetchedBeforeCompletion < 2 {
+		t.Errorf("Got: %v simulation runs executed at the same time - Expected 2", fetchedBeforeCompletion)
+	}
+}
+
+func TestSimRunShouldKillExecutorExceedingTimeConstraint(t *testing.T) {
+	// === GIVEN ===
+	rootDir, _ := ioutil.TempDir("", "scalarm_sim_test")
+	defer os.RemoveAll(rootDir)
+
+	codeBase := createCodeBase(t, map[string]string{
+		"executor":      "#!/bin/sh\nsleep 30\n",
+		"output_reader": "#!/bin/sh\necho 'output reader executed'\n",
+	})
+
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{
+		ID:                   "3",
+		CodeBase:             codeBase,
+		InputParameters:      []map[string]interface{}{{"parameter1": 1}},
+		ExecutionConstraints: map[string]interface{}{"time_constraint_in_sec": 1},
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := SimulationManagerConfig{
+		ExperimentId:          "3",
+		InformationServiceUrl: "www.example.com/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		Development:           true,
+		Timeout:               2,
+		CooldownInterval:      1,
+	}
+
+	sim := SimulationManager{
+		Config:      &config,
+		HttpClient:  getHttpClientMock(server.URL),
+		RootDirPath: rootDir,
+	}
+
+	// === WHEN ===
+	start := time.Now()
+	sim.Run()
+
+	// === THEN ===
+	if time.Since(start) > 20*time.Second {
+		t.Errorf("Executor has not been killed after exceeding its time constraint")
+	}
+
+	results, _ := fake.Completion("3", 1)
+	if results.Values.Get("status") != "error" {
+		t.Errorf("Got: '%v' - Expected 'error'", results.Values.Get("status"))
+	}
+
+	expectedReason := "Simulation run exceeded time constraint of 1 seconds and has been killed"
+	if results.Values.Get("reason") != expectedReason {
+		t.Errorf("Got: '%v' - Expected '%v'", results.Values.Get("reason"), expectedReason)
+	}
+
+	uploads := fake.Uploads()
+	if len(uploads) != 1 || uploads[0].Kind != "stdout" || !strings.Contains(string(uploads[0].Content), "output reader executed") {
+		t.Errorf("Output reader has not been executed after killing the executor")
+	}
+}
+
+func TestTimeConstraintShouldBeReadFromExecutionConstraints(t *testing.T) {
+	simulationRun := &SimulationRunConfig{}
+	json.Unmarshal([]byte(`{"status":"ok","execution_constraints":{"time_constraint_in_sec":3300}}`), simulationRun)
+
+	if simulationRun.TimeConstraint() != 3300*time.Second {
+		t.Errorf("Got: '%v' - Expected '%v'", simulationRun.TimeConstraint(), 3300*time.Second)
+	}
+
+	if (&SimulationRunConfig{Status: "ok"}).TimeConstraint() != 0 {
+		t.Errorf("Got: '%v' - Expected no time constraint", (&SimulationRunConfig{Status: "ok"}).TimeConstraint())
+	}
+}
+
+func TestSimRunShouldReportFailedAdapterAndContinueWithNextSimulationRun(t *testing.T) {
+	// === GIVEN ===
+	rootDir, _ := ioutil.TempDir("", "scalarm_sim_test")
+	defer os.RemoveAll(rootDir)
+
+	codeBase := createCodeBase(t, map[string]string{
+		"executor": "#!/bin/sh\necho 'computing'\nif grep -q '\"parameter1\":1' input.json; then exit 3; fi\n" +
+			"echo '{\"status\":\"ok\",\"results\":{\"product\":2}}' > output.json\n",
+	})
+
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{
+		ID:              "4",
+		CodeBase:        codeBase,
+		InputParameters: []map[string]interface{}{{"parameter1": 1}, {"parameter1": 2}},
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := SimulationManagerConfig{
+		ExperimentId:          "4",
+		InformationServiceUrl: "www.example.com/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		Development:           true,
+		Timeout:               2,
+		CooldownInterval:      1,
+	}
+
+	sim := SimulationManager{
+		Config:      &config,
+		HttpClient:  getHttpClientMock(server.URL),
+		RootDirPath: rootDir,
+	}
+
+	// === WHEN ===
+	sim.Run()
+
+	// === THEN ===
+	failedRun, _ := fake.Completion("4", 1)
+	if failedRun.Values.Get("status") != "error" {
+		t.Errorf("Got: '%v' - Expected 'error'", failedRun.Values.Get("status"))
+	}
+
+	expectedReason := "'executor' failed with exit code 3 (exit status 3), last lines of _stdout.txt:\ncomputing"
+	if failedRun.Values.Get("reason") != expectedReason {
+		t.Errorf("Got: '%v' - Expected '%v'", failedRun.Values.Get("reason"), expectedReason)
+	}
+
+	uploads := fake.Uploads()
+	if len(uploads) == 0 || uploads[0].SimulationID != 1 || uploads[0].Kind != "stdout" || string(uploads[0].Content) != "computing\n" {
+		t.Errorf("Got: '%+v' - Expected stdout of the failed run to be uploaded", uploads)
+	}
+
+	if nextRun, _ := fake.Completion("4", 2); nextRun.Values.Get("status") != "ok" {
+		t.Errorf("Got: '%v' - Expected next simulation run to be executed", nextRun.Values)
+	}
+}
+
+func TestSimRunShouldFinishInFlightSimulationRunsAndExitOnSigterm(t *testing.T) {
+	// === GIVEN ===
+	rootDir, _ := ioutil.TempDir("", "scalarm_sim_test")
+	defer os.RemoveAll(rootDir)
+
+	codeBase := createCodeBase(t, map[string]string{
+		"executor": "#!/bin/sh\n" +
+			"if grep -q '\"parameter1\":1' input.json; then\n" +
+			"  trap 'echo \"{\\\"status\\\":\\\"ok\\\",\\\"results\\\":{\\\"partial\\\":1}}\" > output.json; exit 0' TERM\n" +
+			"fi\n" +
+			"sleep 30 &\nwait\n",
+	})
+
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{
+		ID:              "5",
+		CodeBase:        codeBase,
+		InputParameters: []map[string]interface{}{{"parameter1": 1}, {"parameter1": 2}, {"parameter1": 3}},
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := SimulationManagerConfig{
+		ExperimentId:          "5",
+		InformationServiceUrl: "www.example.com/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		Development:           true,
+		Timeout:               2,
+		CooldownInterval:      1,
+		ParallelSlots:         2,
+		ShutdownGracePeriod:   1,
+	}
+
+	sim := SimulationManager{
+		Config:      &config,
+		HttpClient:  getHttpClientMock(server.URL),
+		RootDirPath: rootDir,
+	}
+
+	go func() {
+		time.Sleep(3 * time.Second)
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	}()
+
+	// === WHEN ===
+	start := time.Now()
+	err := sim.Run()
+
+	// === THEN ===
+	if time.Since(start) > 20*time.Second {
+		t.Errorf("Simulation runs have not been interrupted")
+	}
+
+	if shutdownErr, ok := err.(*ShutdownError); !ok || shutdownErr.Signal != syscall.SIGTERM {
+		t.Errorf("Got: '%v' - Expected '%v'", err, &ShutdownError{Signal: syscall.SIGTERM})
+	}
+
+	if fetched := fetchedSimulationRuns(fake, "5"); fetched != 2 {
+		t.Errorf("Got: %v simulation runs fetched - Expected 2", fetched)
+	}
+
+	partialRun, _ := fake.Completion("5", 1)
+	if partialRun.Values.Get("status") != "ok" || partialRun.Values.Get("result") != `{"partial":1}` {
+		t.Errorf("Got: '%v' - Expected partial results to be reported", partialRun.Values)
+	}
+
+	interruptedRun, _ := fake.Completion("5", 2)
+	if interruptedRun.Values.Get("status") != "error" || interruptedRun.Values.Get("reason") != "Simulation run has been interrupted by terminated signal" {
+		t.Errorf("Got: '%v' - Expected interrupted simulation run to be reported as failed", interruptedRun.Values)
+	}
+}
+
+func TestSimRunShouldNotStartSimulationRunsAfterDeadline(t *testing.T) {
+	// === GIVEN ===
+	rootDir, _ := ioutil.TempDir("", "scalarm_sim_test")
+	defer os.RemoveAll(rootDir)
+
+	codeBase := createCodeBase(t, map[string]string{
+		"executor": "#!/bin/sh\nsleep 2\necho '{\"status\":\"ok\",\"results\":{\"product\":1}}' > output.json\n",
+	})
+
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{
+		ID:              "6",
+		CodeBase:        codeBase,
+		InputParameters: []map[string]interface{}{{"parameter1": 1}, {"parameter1": 1}, {"parameter1": 1}},
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := SimulationManagerConfig{
+		ExperimentId:          "6",
+		InformationServiceUrl: "www.example.com/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		Development:           true,
+		Timeout:               2,
+		CooldownInterval:      1,
+		Deadline:              "3s",
+	}
+
+	sim := SimulationManager{
+		Config:      &config,
+		HttpClient:  getHttpClientMock(server.URL),
+		RootDirPath: rootDir,
+	}
+
+	// === WHEN ===
+	sim.Run()
+
+	// === THEN ===
+	if fetched := fetchedSimulationRuns(fake, "6"); fetched != 1 {
+		t.Errorf("Got: %v simulation runs fetched - Expected 1", fetched)
+	}
+}
+
+func TestSimRunShouldNotExecuteFetchedSimulationRunWithTimeConstraintExceedingDeadline(t *testing.T) {
+	// === GIVEN ===
+	rootDir, _ := ioutil.TempDir("", "scalarm_sim_test")
+	defer os.RemoveAll(rootDir)
+
+	codeBase := createCodeBase(t, map[string]string{
+		"executor": "#!/bin/sh\necho '{\"status\":\"ok\",\"results\":{\"product\":1}}' > output.json\n",
+	})
+
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{
+		ID:                   "6",
+		CodeBase:             codeBase,
+		InputParameters:      []map[string]interface{}{{"parameter1": 1}, {"parameter1": 2}},
+		ExecutionConstraints: map[string]interface{}{"time_constraint_in_sec": 3600},
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := SimulationManagerConfig{
+		ExperimentId:          "6",
+		InformationServiceUrl: "www.example.com/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		Development:           true,
+		Timeout:               2,
+		CooldownInterval:      1,
+		Deadline:              "30m",
+	}
+
+	sim := SimulationManager{
+		Config:      &config,
+		HttpClient:  getHttpClientMock(server.URL),
+		RootDirPath: rootDir,
+	}
+
+	// === WHEN ===
+	sim.Run()
+
+	// === THEN ===
+	if completions := fake.Completions(); len(completions) != 0 {
+		t.Errorf("Got: %v simulation runs completed - Expected 0", len(completions))
+	}
+
+	if fetched := fetchedSimulationRuns(fake, "6"); fetched != 1 {
+		t.Errorf("Got: %v simulation runs fetched - Expected 1", fetched)
+	}
+}
+
+func TestSimRunShouldGiveBackFetchedSimulationRunWithTimeConstraintExceedingDeadline(t *testing.T) {
+	// === GIVEN ===
+	rootDir, _ := ioutil.TempDir("", "scalarm_sim_test")
+	defer os.RemoveAll(rootDir)
+
+	codeBase := createCodeBase(t, map[string]string{
+		"executor": "#!/bin/sh\necho '{\"status\":\"ok\",\"results\":{\"product\":1}}' > output.json\n",
+	})
+
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{
+		ID:                   "6",
+		CodeBase:             codeBase,
+		InputParameters:      []map[string]interface{}{{"parameter1": 1}, {"parameter1": 2}},
+		ExecutionConstraints: map[string]interface{}{"time_constraint_in_sec": 3600},
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := SimulationManagerConfig{
+		ExperimentId:          "6",
+		InformationServiceUrl: "www.example.com/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		Development:           true,
+		Timeout:               2,
+		CooldownInterval:      1,
+		Deadline:              "30m",
+	}
+
+	sim := SimulationManager{
+		Config:      &config,
+		HttpClient:  getHttpClientMock(server.URL),
+		RootDirPath: rootDir,
+	}
+	sim.Run()
+
+	// === WHEN ===
+	config.Deadline = ""
+	nextSim := SimulationManager{
+		Config:      &config,
+		HttpClient:  getHttpClientMock(server.URL),
+		RootDirPath: rootDir,
+	}
+	nextSim.Run()
+
+	// === THEN ===
+	givenBack := false
+	for _, request := range fake.Requests() {
+		givenBack = givenBack || request == "DELETE /experiments/6/simulations/1"
+	}
+	if !givenBack {
+		t.Errorf("Got: %v - Expected simulation run 1 to be given back", fake.Requests())
+	}
+
+	if _, ok := fake.Completion("6", 1); !ok {
+		t.Error("Got: simulation run 1 not completed - Expected it to be sent again and completed")
+	}
+
+	if completions := fake.Completions(); len(completions) != 2 {
+		t.Errorf("Got: %v simulation runs completed - Expected 2", len(completions))
+	}
+}
+
+func TestSimRunContextShouldKillSimulationRunsAndReturnWhenContextIsCanceled(t *testing.T) {
+	// === GIVEN ===
+	rootDir, _ := ioutil.TempDir("", "scalarm_sim_test")
+	defer os.RemoveAll(rootDir)
+
+	codeBase := createCodeBase(t, map[string]string{
+		"executor": "#!/bin/sh\ntrap '' TERM\nsleep 30 &\nwait\n",
+	})
+
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{
+		ID:              "7",
+		CodeBase:        codeBase,
+		InputParameters: []map[string]interface{}{{"parameter1": 1}, {"parameter1": 1}, {"parameter1": 1}},
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := SimulationManagerConfig{
+		ExperimentId:          "7",
+		InformationServiceUrl: "www.example.com/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		Development:           true,
+		Timeout:               2,
+		CooldownInterval:      1,
+		ParallelSlots:         2,
+	}
+
+	sim := SimulationManager{
+		Config:      &config,
+		HttpClient:  getHttpClientMock(server.URL),
+		RootDirPath: rootDir,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(2*time.Second, cancel)
+
+	// === WHEN ===
+	start := time.Now()
+	err := sim.RunContext(ctx)
+
+	// === THEN ===
+	if err != context.Canceled {
+		t.Errorf("Got: '%v' - Expected '%v'", err, context.Canceled)
+	}
+
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("Simulation runs have not been killed")
+	}
+
+	if fetched, completed := fetchedSimulationRuns(fake, "7"), len(fake.Completions()); fetched != 2 || completed != 0 {
+		t.Errorf("Got: %v simulation runs fetched, %v completed - Expected 2 fetched, 0 completed", fetched, completed)
+	}
+}
+
+func TestSimRunShouldKeepResultsInOutboxAndSendThemBeforeFetchingNewRunsAfterRestart(t *testing.T) {
+	// === GIVEN ===
+	rootDir, _ := ioutil.TempDir("", "scalarm_sim_test")
+	defer os.RemoveAll(rootDir)
+
+	codeBase := createCodeBase(t, map[string]string{
+		"executor": "#!/bin/sh\necho '{\"status\":\"ok\",\"results\":{\"product\":1}}' > output.json\n",
+	})
+
+	fake := fakeScalarm.NewServer(fakeScalarm.Experiment{
+		ID:              "8",
+		CodeBase:        codeBase,
+		InputParameters: []map[string]interface{}{{"parameter1": 1}},
+	})
+
+	// Experiment Manager fails to mark simulation runs as complete until it is up again
+	var experimentManagerUp int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/mark_as_complete") && atomic.LoadInt32(&experimentManagerUp) == 0 {
+			w.WriteHeader(500)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	config := SimulationManagerConfig{
+		ExperimentId:          "8",
+		InformationServiceUrl: "www.example.com/information",
+		ExperimentManagerUser: "user",
+		ExperimentManagerPass: "pass",
+		Development:           true,
+		Timeout:               2,
+		CooldownInterval:      1,
+		RetryMaxAttempts:      1,
+		// the fake waits for results of the simulation run which are kept in the outbox
+		SimulationsLimit: 1,
+	}
+
+	sim := SimulationManager{
+		Config:      &config,
+		HttpClient:  getHttpClientMock(server.URL),
+		RootDirPath: rootDir,
+	}
+
+	// === WHEN ===
+	firstErr := sim.Run()
+
+	atomic.StoreInt32(&experimentManagerUp, 1)
+	firstRunRequests := len(fake.Requests())
+	config.SimulationsLimit = 0
+
+	secondErr := sim.Run()
+
+	// === THEN ===
+	if firstErr != ErrSimulationsLimitReached || secondErr != nil {
+		t.Fatalf("Got: '%v', '%v' - Expected '%v', nil", firstErr, secondErr, ErrSimulationsLimitReached)
+	}
+
+	requests := []string{}
+	for _, request := range fake.Requests()[firstRunRequests:] {
+		if strings.Contains(request, "/experiments/8/") && !strings.HasSuffix(request, "/code_base") {
+			requests = append(requests, request)
+		}
+	}
+
+	expected := []string{
+		"POST /experiments/8/simulations/1/mark_as_complete",
+		"PUT /experiments/8/simulations/1/stdout",
+		"GET /experiments/8/next_simulation",
+	}
+	if len(requests) < len(expected) || !reflect.DeepEqual(requests[:len(expected)], expected) {
+		t.Errorf("Got: '%v' - Expected to start with '%v'", requests, expected)
+	}
+
+	if files, _ := ioutil.ReadDir(path.Join(rootDir, "outbox")); len(files) != 0 {
+		t.Errorf("Got: %v files - Expected empty outbox", len(files))
+	}
+}
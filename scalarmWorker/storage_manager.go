@@ -0,0 +1,103 @@
+package scalarmWorker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// StorageManager uploads binary results and stdout of simulation runs
+type StorageManager struct {
+	HttpClient           *http.Client
+	Endpoints            *EndpointPool
+	CommunicationTimeout time.Duration
+	Config               *SimulationManagerConfig
+}
+
+// client returns the client executing requests against Storage Managers
+func (sm *StorageManager) client() *ScalarmClient {
+	return &ScalarmClient{
+		HttpClient: sm.HttpClient,
+		Endpoints:  sm.Endpoints,
+		Config:     sm.Config,
+		Timeout:    sm.CommunicationTimeout,
+		Service:    "Storage manager",
+	}
+}
+
+func simulationRunStoragePath(experimentID string, simulationIndex int) string {
+	return "experiments/" + experimentID + "/simulations/" + strconv.Itoa(simulationIndex)
+}
+
+// UploadSimulationRunOutputContext uploads the binary output of a simulation run in chunks which are resumed
+// after failures; when Storage Manager does not support it, the file is sent with a single PUT.
+// Storage Manager stores the file under fileName, e.g. output.tar.gz, whatever the name of filePath is
+func (sm *StorageManager) UploadSimulationRunOutputContext(ctx context.Context, experimentID string, simulationIndex int,
+	filePath, fileName string) error {
+	simulationPath := simulationRunStoragePath(experimentID, simulationIndex)
+
+	size, err := sm.checkUploadSize(filePath, fileName)
+	if err != nil {
+		return err
+	}
+
+	if err := sm.uploadChunked(ctx, simulationPath, filePath, fileName, size); err != errChunkedUploadUnsupported {
+		return err
+	}
+
+	fmt.Println("[SiM] Storage manager does not support chunked uploads, sending the whole file")
+	return sm.uploadFile(ctx, simulationPath, filePath, fileName)
+}
+
+// UploadSimulationRunStdoutContext uploads the standard output of a simulation run under fileName, e.g. _stdout.txt
+func (sm *StorageManager) UploadSimulationRunStdoutContext(ctx context.Context, experimentID string, simulationIndex int,
+	filePath, fileName string) error {
+	if _, err := sm.checkUploadSize(filePath, fileName); err != nil {
+		return err
+	}
+
+	return sm.uploadFile(ctx, simulationRunStoragePath(experimentID, simulationIndex)+"/stdout", filePath, fileName)
+}
+
+// UploadSizeLimitError is returned when a file is bigger than upload_size_limit, it is not sent
+type UploadSizeLimitError struct {
+	Path     string
+	FileName string
+	Size     int64
+	Limit    int
+}
+
+func (e *UploadSizeLimitError) Error() string {
+	return fmt.Sprintf("'%s' has %v bytes which exceeds upload_size_limit of %v MB", e.FileName, e.Size, e.Limit)
+}
+
+// checkUploadSize returns size of the file or UploadSizeLimitError when it must not be uploaded
+func (sm *StorageManager) checkUploadSize(filePath, fileName string) (int64, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit := sm.Config.UploadSizeLimit; limit > 0 && info.Size() > int64(limit)*1024*1024 {
+		return 0, &UploadSizeLimitError{Path: filePath, FileName: fileName, Size: info.Size(), Limit: limit}
+	}
+
+	return info.Size(), nil
+}
+
+// uploadFile streams the file named fileName as the 'file' field of a multipart form with PUT,
+// callers check its size against upload_size_limit
+func (sm *StorageManager) uploadFile(ctx context.Context, serviceMethod, filePath, fileName string) error {
+	body := NewMultipartFileBody("file", filePath, fileName)
+	request := ScalarmRequest{Method: "PUT", ServiceMethod: serviceMethod, Body: body, ContentType: body.ContentType()}
+	response, err := sm.client().Read(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[SiM] Response body: %s\n", response)
+	return nil
+}
@@ -0,0 +1,232 @@
+package scalarmWorker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getStorageManagerMock(serverURL string, config *SimulationManagerConfig) *StorageManager {
+	return &StorageManager{
+		HttpClient:           getHttpClientMock(serverURL),
+		Endpoints:            NewEndpointPool([]string{"storage.scalarm.com"}),
+		CommunicationTimeout: time.Second,
+		Config:               config,
+	}
+}
+
+func TestStorageManagerShouldStreamOutputWithSinglePutWhenChunkedUploadsAreNotSupported(t *testing.T) {
+	// === GIVEN ===
+	var contentLength int64
+	uploadPath, fileName, content := "", "", ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/uploads") {
+			http.NotFound(w, r)
+			return
+		}
+		contentLength = r.ContentLength
+		uploadPath = r.Method + " " + r.URL.Path
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		defer file.Close()
+		body, _ := ioutil.ReadAll(file)
+		fileName, content = header.Filename, string(body)
+	}))
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "scalarm_upload")
+	defer os.RemoveAll(dir)
+	outputPath := path.Join(dir, "output.tar.gz")
+	ioutil.WriteFile(outputPath, []byte(strings.Repeat("binary output ", 100000)), 0600)
+
+	body := NewMultipartFileBody("file", outputPath, "output.tar.gz")
+	sm := getStorageManagerMock(server.URL, getSimConfig())
+
+	// === WHEN ===
+	err := sm.UploadSimulationRunOutputContext(context.Background(), "3", 4, outputPath, "output.tar.gz")
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	if uploadPath != "PUT /experiments/3/simulations/4" {
+		t.Errorf("Got: '%v' - Expected 'PUT /experiments/3/simulations/4'", uploadPath)
+	}
+
+	if contentLength != body.Size() || contentLength <= 1400000 {
+		t.Errorf("Got: %v - Expected content length %v", contentLength, body.Size())
+	}
+
+	if fileName != "output.tar.gz" || content != strings.Repeat("binary output ", 100000) {
+		t.Errorf("Got: '%v' with %v bytes - Expected 'output.tar.gz' with 1400000 bytes", fileName, len(content))
+	}
+}
+
+func TestStorageManagerShouldNotUploadFileExceedingSizeLimit(t *testing.T) {
+	// === GIVEN ===
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "scalarm_upload")
+	defer os.RemoveAll(dir)
+	stdoutPath := path.Join(dir, "_stdout.txt")
+	ioutil.WriteFile(stdoutPath, make([]byte, 1024*1024+1), 0600)
+
+	config := getSimConfig()
+	config.UploadSizeLimit = 1
+	sm := getStorageManagerMock(server.URL, config)
+
+	// === WHEN ===
+	err := sm.UploadSimulationRunStdoutContext(context.Background(), "3", 4, stdoutPath, "_stdout.txt")
+
+	// === THEN ===
+	expectedMsg := "'_stdout.txt' has 1048577 bytes which exceeds upload_size_limit of 1 MB"
+	if _, ok := err.(*UploadSizeLimitError); !ok || err.Error() != expectedMsg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expectedMsg)
+	}
+
+	if requests != 0 {
+		t.Errorf("Got: %v requests - Expected 0", requests)
+	}
+}
+
+// chunkedStorageMock is Storage Manager supporting chunked uploads of a single file
+type chunkedStorageMock struct {
+	chunkSize     int64
+	stored        []byte
+	chunkOffsets  []int64
+	lostAcks      map[int64]bool
+	completedWith string
+	// bytes added to acknowledged offsets of chunks by a misbehaving Storage Manager
+	overAck int64
+}
+
+func (m *chunkedStorageMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/uploads"):
+		fmt.Fprintf(w, `{"status":"ok","upload_id":"u1","offset":%v,"chunk_size":%v}`, len(m.stored), m.chunkSize)
+	case r.Method == "PUT" && strings.Contains(r.URL.Path, "/uploads/u1/chunks/"):
+		offset, _ := strconv.ParseInt(path.Base(r.URL.Path), 10, 64)
+		chunk, _ := ioutil.ReadAll(r.Body)
+		sum := sha256.Sum256(chunk)
+		if r.Header.Get("Digest") != "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]) || offset != int64(len(m.stored)) {
+			fmt.Fprintf(w, `{"status":"error","reason":"incorrect chunk","offset":%v}`, len(m.stored))
+			return
+		}
+		m.chunkOffsets = append(m.chunkOffsets, offset)
+		m.stored = append(m.stored, chunk...)
+		if m.lostAcks[offset] {
+			delete(m.lostAcks, offset)
+			w.WriteHeader(500)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"ok","offset":%v}`, int64(len(m.stored))+m.overAck)
+	case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/uploads/u1"):
+		fmt.Fprintf(w, `{"status":"ok","offset":%v}`, len(m.stored))
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/uploads/u1/complete"):
+		sum := sha256.Sum256(m.stored)
+		m.completedWith = hex.EncodeToString(sum[:])
+		fmt.Fprint(w, `{"status":"ok"}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestStorageManagerShouldResumeChunkedUploadFromAcknowledgedOffset(t *testing.T) {
+	// === GIVEN ===
+	storage := &chunkedStorageMock{chunkSize: 4, lostAcks: map[int64]bool{4: true}}
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "scalarm_upload")
+	defer os.RemoveAll(dir)
+	outputPath := path.Join(dir, "output.tar.gz")
+	ioutil.WriteFile(outputPath, []byte("0123456789"), 0600)
+
+	sm := getStorageManagerMock(server.URL, getSimConfig())
+
+	// === WHEN ===
+	err := sm.UploadSimulationRunOutputContext(context.Background(), "3", 4, outputPath, "output.tar.gz")
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	if !reflect.DeepEqual(storage.chunkOffsets, []int64{0, 4, 8}) || string(storage.stored) != "0123456789" {
+		t.Errorf("Got: '%v', '%s' - Expected '[0 4 8]', '0123456789'", storage.chunkOffsets, storage.stored)
+	}
+
+	checksum, _ := fileChecksum(outputPath)
+	if storage.completedWith != checksum {
+		t.Errorf("Got: '%v' - Expected upload completed with '%v'", storage.completedWith, checksum)
+	}
+}
+
+func TestStorageManagerShouldContinueUploadStartedBeforeRestart(t *testing.T) {
+	// === GIVEN ===
+	storage := &chunkedStorageMock{chunkSize: 4, stored: []byte("01234567")}
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "scalarm_upload")
+	defer os.RemoveAll(dir)
+	outputPath := path.Join(dir, "output.tar.gz")
+	ioutil.WriteFile(outputPath, []byte("0123456789"), 0600)
+
+	sm := getStorageManagerMock(server.URL, getSimConfig())
+
+	// === WHEN ===
+	err := sm.UploadSimulationRunOutputContext(context.Background(), "3", 4, outputPath, "output.tar.gz")
+
+	// === THEN ===
+	if err != nil || !reflect.DeepEqual(storage.chunkOffsets, []int64{8}) || string(storage.stored) != "0123456789" {
+		t.Errorf("Got: '%v', '%v', '%s' - Expected nil, '[8]', '0123456789'", err, storage.chunkOffsets, storage.stored)
+	}
+}
+
+func TestStorageManagerShouldRejectAcknowledgementOfBytesWhichWereNotSent(t *testing.T) {
+	// === GIVEN ===
+	storage := &chunkedStorageMock{chunkSize: 4, overAck: 2}
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "scalarm_upload")
+	defer os.RemoveAll(dir)
+	outputPath := path.Join(dir, "output.tar.gz")
+	ioutil.WriteFile(outputPath, []byte("0123456789"), 0600)
+
+	sm := getStorageManagerMock(server.URL, getSimConfig())
+
+	// === WHEN ===
+	err := sm.UploadSimulationRunOutputContext(context.Background(), "3", 4, outputPath, "output.tar.gz")
+
+	// === THEN ===
+	expectedMsg := "Storage manager acknowledged 6 bytes of 'output.tar.gz' while only 4 were sent"
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expectedMsg)
+	}
+
+	if !reflect.DeepEqual(storage.chunkOffsets, []int64{0}) || storage.completedWith != "" {
+		t.Errorf("Got: '%v', '%v' - Expected a single chunk and no completed upload", storage.chunkOffsets, storage.completedWith)
+	}
+}
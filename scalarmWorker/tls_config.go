@@ -0,0 +1,128 @@
+package scalarmWorker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+)
+
+// supported values of 'tls_min_version'
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	// tls.VersionTLS13, not defined before Go 1.12
+	"1.3": 0x0304,
+}
+
+// NewTLSConfig creates TLS settings of connections to Scalarm services: when 'scalarm_certificate_path' is set,
+// only CA certificates from the config are trusted, otherwise they are appended to the system pool;
+// the client certificate is used for mutual TLS
+func NewTLSConfig(config *SimulationManagerConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSSL}
+
+	caFiles, caDirFiles, err := caCertificateFiles(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(caFiles) > 0 || config.CACertificateDir != "" {
+		caPool, err := baseCertPool(config)
+		if err != nil {
+			fmt.Printf("[SiM] Could not load system CA certificates, only the configured ones are used: %v\n", err)
+			caPool = x509.NewCertPool()
+		}
+
+		for _, caFile := range caFiles {
+			caCertificates, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("Could not load CA certificate %s: %v", caFile, err)
+			}
+
+			if !caPool.AppendCertsFromPEM(caCertificates) {
+				return nil, errors.New("There is no PEM certificate in " + caFile + ".")
+			}
+		}
+
+		// CA directories of grid sites keep also signing policies, namespaces and CRLs next to certificates
+		caDirCertificates := false
+		for _, caFile := range caDirFiles {
+			caCertificates, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("Could not load CA certificate %s: %v", caFile, err)
+			}
+
+			if caPool.AppendCertsFromPEM(caCertificates) {
+				caDirCertificates = true
+			}
+		}
+
+		// otherwise a mistyped or empty directory would end in a certificate verification error of the first request
+		if config.CACertificateDir != "" && !caDirCertificates {
+			return nil, errors.New("There is no PEM certificate in the directory " + config.CACertificateDir + ".")
+		}
+
+		tlsConfig.RootCAs = caPool
+	}
+
+	if config.ClientCertificatePath != "" || config.ClientKeyPath != "" {
+		certificate, err := tls.LoadX509KeyPair(config.ClientCertificatePath, config.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load client certificate: %v", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if config.TLSMinVersion != "" {
+		version, ok := tlsVersions[config.TLSMinVersion]
+		if !ok {
+			return nil, errors.New("Unsupported TLS version '" + config.TLSMinVersion + "'.")
+		}
+
+		tlsConfig.MinVersion = version
+	}
+
+	return tlsConfig, nil
+}
+
+// baseCertPool returns the pool the configured CA certificates are appended to: an empty one when
+// 'scalarm_certificate_path' is set, so no public CA can impersonate Scalarm services, the system one otherwise
+func baseCertPool(config *SimulationManagerConfig) (*x509.CertPool, error) {
+	if config.ScalarmCertificatePath != "" {
+		return x509.NewCertPool(), nil
+	}
+
+	return x509.SystemCertPool()
+}
+
+// caCertificateFiles returns configured CA certificate files, which have to contain certificates,
+// and files from the CA directory in name order, which are skipped when they contain no certificates
+func caCertificateFiles(config *SimulationManagerConfig) ([]string, []string, error) {
+	caFiles := []string{}
+
+	if config.ScalarmCertificatePath != "" {
+		caFiles = append(caFiles, config.ScalarmCertificatePath)
+	}
+
+	caFiles = append(caFiles, config.CACertificatePaths...)
+
+	caDirFiles := []string{}
+	if config.CACertificateDir != "" {
+		entries, err := ioutil.ReadDir(config.CACertificateDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Could not read CA certificates directory: %v", err)
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				caDirFiles = append(caDirFiles, path.Join(config.CACertificateDir, entry.Name()))
+			}
+		}
+	}
+
+	return caFiles, caDirFiles, nil
+}
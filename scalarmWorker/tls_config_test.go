@@ -0,0 +1,180 @@
+package scalarmWorker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeClientCertificate writes a self-signed client certificate and its key into the directory
+func writeClientCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "worker"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificatePath := path.Join(dir, "client.pem")
+	keyPath := path.Join(dir, "client.key")
+	ioutil.WriteFile(certificatePath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0600)
+	ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600)
+
+	return certificatePath, keyPath
+}
+
+func TestNewTLSConfigShouldTrustCADirectoryAndSendClientCertificate(t *testing.T) {
+	// === GIVEN ===
+	clientCertificates := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCertificates = len(r.TLS.PeerCertificates)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "tls_config")
+	defer os.RemoveAll(dir)
+
+	caDir := path.Join(dir, "ca")
+	os.Mkdir(caDir, 0700)
+	serverCertificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	ioutil.WriteFile(path.Join(caDir, "1a2b3c4d.0"), serverCertificate, 0600)
+	// files kept next to certificates in CA directories of grid sites
+	ioutil.WriteFile(path.Join(caDir, "1a2b3c4d.signing_policy"), []byte("access_id_CA X509 '/CN=Scalarm CA'"), 0600)
+	ioutil.WriteFile(path.Join(caDir, "1a2b3c4d.namespaces"), []byte("TO Issuer \"/CN=Scalarm CA\" PERMIT Subject \".*\""), 0600)
+	ioutil.WriteFile(path.Join(caDir, "1a2b3c4d.crl_url"), []byte("http://example.com/ca.crl"), 0600)
+
+	config := &SimulationManagerConfig{CACertificateDir: caDir, TLSMinVersion: "1.2"}
+	config.ClientCertificatePath, config.ClientKeyPath = writeClientCertificate(t, dir)
+
+	// === WHEN ===
+	tlsConfig, err := NewTLSConfig(config)
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	resp, err := client.Get(server.URL)
+
+	// === THEN ===
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected trusted server certificate", err)
+	}
+	resp.Body.Close()
+
+	if clientCertificates != 1 {
+		t.Errorf("Got: %v client certificates - Expected 1", clientCertificates)
+	}
+
+	if tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Got: %v - Expected TLS 1.2", tlsConfig.MinVersion)
+	}
+}
+
+func TestNewTLSConfigShouldReturnErrorWhenCAFileHasNoCertificates(t *testing.T) {
+	// === GIVEN ===
+	caFile, _ := ioutil.TempFile("", "ca")
+	defer os.Remove(caFile.Name())
+	ioutil.WriteFile(caFile.Name(), []byte("not a certificate"), 0600)
+
+	config := &SimulationManagerConfig{CACertificatePaths: []string{caFile.Name()}}
+
+	// === WHEN ===
+	tlsConfig, err := NewTLSConfig(config)
+
+	// === THEN ===
+	if tlsConfig != nil {
+		t.Errorf("Got: '%v' - Expected nil", tlsConfig)
+	}
+
+	expectedMsg := "There is no PEM certificate in " + caFile.Name() + "."
+	if err == nil || err.Error() != expectedMsg {
+		t.Errorf("Got: '%v' - Expected '%v'", err, expectedMsg)
+	}
+}
+
+func TestNewTLSConfigShouldReturnErrorWhenCADirectoryHasNoCertificates(t *testing.T) {
+	// === GIVEN ===
+	caDir, _ := ioutil.TempDir("", "ca")
+	defer os.RemoveAll(caDir)
+	ioutil.WriteFile(path.Join(caDir, "1a2b3c4d.signing_policy"), []byte("access_id_CA X509 '/CN=Scalarm CA'"), 0600)
+	os.Mkdir(path.Join(caDir, "empty"), 0700)
+
+	for _, dir := range []string{caDir, path.Join(caDir, "empty")} {
+		config := &SimulationManagerConfig{CACertificateDir: dir}
+
+		// === WHEN ===
+		tlsConfig, err := NewTLSConfig(config)
+
+		// === THEN ===
+		if tlsConfig != nil {
+			t.Errorf("Got: '%v' - Expected nil", tlsConfig)
+		}
+
+		expectedMsg := "There is no PEM certificate in the directory " + dir + "."
+		if err == nil || err.Error() != expectedMsg {
+			t.Errorf("Got: '%v' - Expected '%v'", err, expectedMsg)
+		}
+	}
+}
+
+func TestNewTLSConfigShouldTrustOnlyScalarmCertificateWhenItIsSet(t *testing.T) {
+	// === GIVEN ===
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "tls_config")
+	defer os.RemoveAll(dir)
+
+	// certificate of another CA than the one which signed the server certificate
+	scalarmCertificatePath, _ := writeClientCertificate(t, dir)
+	config := &SimulationManagerConfig{ScalarmCertificatePath: scalarmCertificatePath}
+
+	// === WHEN ===
+	tlsConfig, err := NewTLSConfig(config)
+	if err != nil {
+		t.Fatalf("Got: '%v' - Expected nil", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	_, requestErr := client.Get(server.URL)
+
+	// === THEN ===
+	if requestErr == nil || !strings.Contains(requestErr.Error(), "certificate") {
+		t.Errorf("Got: '%v' - Expected server signed by another CA to be rejected", requestErr)
+	}
+
+	if subjects := len(tlsConfig.RootCAs.Subjects()); subjects != 1 {
+		t.Errorf("Got: %v trusted CA certificates - Expected only the Scalarm one", subjects)
+	}
+}